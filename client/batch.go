@@ -0,0 +1,152 @@
+package client //nolint // Package comment located in a different file.
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+	"github.com/hojulian/microdb/microdb"
+	mquery "github.com/hojulian/microdb/query"
+)
+
+// Batch writes.
+
+// Statement represents a write query and the args for its placeholder parameters.
+type Statement struct {
+	Query string
+	Args  []interface{}
+}
+
+// ExecuteBatch executes statements in a single transaction of the data origin, and returns the
+// result of each of them. Either all statements are executed, or none of them.
+//
+// All statements must write to tables of the same data origin connection. If a statement fails,
+// the returned *QueryError holds its index.
+func (c *Client) ExecuteBatch(ctx context.Context, stmts []Statement) ([]sql.Result, error) {
+	pbStmts := make([]*pb.Statement, 0, len(stmts))
+	for _, s := range stmts {
+		pbStmts = append(pbStmts, &pb.Statement{
+			Query: s.Query,
+			Args:  pb.MarshalValues(s.Args),
+		})
+	}
+
+	return executeBatch(ctx, c.nats.natsConn(), c.reg, pbStmts, c.writeRetry, c.cred)
+}
+
+// executeBatch checks that all statements write to the same data origin connection, and sends them
+// to a querier of the table of the first statement.
+func executeBatch(ctx context.Context, nc *nats.Conn, reg *microdb.Registry, stmts []*pb.Statement,
+	wr writeRetry, cred *credentials) ([]sql.Result, error) {
+	if len(stmts) == 0 {
+		return nil, nil
+	}
+
+	var first *microdb.DataOrigin
+	for i, s := range stmts {
+		q, err := mquery.Query(s.Query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query of statement %d: %w", i, err)
+		}
+		if q.GetQueryType() == mquery.QueryTypeSelect {
+			return nil, fmt.Errorf("statement %d: select queries cannot be batched", i)
+		}
+		s.Query = q.SQL()
+
+		do, err := reg.GetDataOrigin(q.GetDestinationTable())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get data origin for table: %w", err)
+		}
+
+		if first == nil {
+			first = do
+		} else if !sameConnection(first, do) {
+			return nil, errors.New("batched statements must write to tables of the same data origin")
+		}
+	}
+
+	res, err := requestWrite(ctx, nc, first.WriteTopic(), &pb.QueryRequest{Statements: stmts}, wr, cred)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := make([]sql.Result, 0, len(res.GetResults()))
+	for _, r := range res.GetResults() {
+		rs = append(rs, r)
+	}
+
+	return rs, nil
+}
+
+func sameConnection(a, b *microdb.DataOrigin) bool {
+	return a.Connection != nil && b.Connection != nil &&
+		a.Connection.OriginType == b.Connection.OriginType && a.Connection.Dsn == b.Connection.Dsn
+}
+
+// connTx is a transaction of a Conn. Writes are sent as a single batch when it is committed.
+//
+// Queries within the transaction read the local tables, which do not reflect its pending writes.
+type connTx struct {
+	c       *Conn
+	stmts   []*pb.Statement
+	results []*pendingResult
+}
+
+// Commit sends the writes of the transaction as a batch.
+func (t *connTx) Commit() error {
+	t.c.tx = nil
+
+	rs, err := executeBatch(context.Background(), t.c.nats.natsConn(), t.c.reg, t.stmts, defaultWriteRetry,
+		t.c.cred)
+	for i, r := range t.results {
+		if err != nil {
+			r.err = err
+			continue
+		}
+		r.res = rs[i]
+	}
+
+	return err
+}
+
+// Rollback discards the writes of the transaction.
+func (t *connTx) Rollback() error {
+	t.c.tx = nil
+
+	for _, r := range t.results {
+		r.err = errors.New("transaction rolled back")
+	}
+
+	return nil
+}
+
+// pendingResult represents the result of a write of a transaction, available once it is committed.
+type pendingResult struct {
+	res sql.Result
+	err error
+}
+
+func (r *pendingResult) LastInsertId() (int64, error) {
+	if r.res == nil {
+		return 0, r.error()
+	}
+	return r.res.LastInsertId() //nolint // Low level sql method, no need for error wrapping
+}
+
+func (r *pendingResult) RowsAffected() (int64, error) {
+	if r.res == nil {
+		return 0, r.error()
+	}
+	return r.res.RowsAffected() //nolint // Low level sql method, no need for error wrapping
+}
+
+func (r *pendingResult) error() error {
+	if r.err != nil {
+		return r.err
+	}
+	return errors.New("result is available once the transaction is committed")
+}
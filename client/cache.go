@@ -0,0 +1,380 @@
+package client //nolint // Package comment located in a different file.
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	mquery "github.com/hojulian/microdb/query"
+)
+
+// Caching results of queries sent to data origins.
+
+const (
+	// DefaultQueryCacheSize is the maximum number of query results cached.
+	DefaultQueryCacheSize = 1024
+	// DefaultQueryCacheBytes is the maximum estimated memory of the query results cached.
+	DefaultQueryCacheBytes = 64 << 20
+	// DefaultMaxCachedResultBytes is the maximum estimated memory of a cached query result. Larger
+	// results are not cached, and are served as they are read from the data origin.
+	DefaultMaxCachedResultBytes = 1 << 20
+)
+
+// EnableQueryCache caches the results of queries sent to data origins for ttl, by query and args.
+// Cached results are dropped once a change of a subscribed table they depend on is applied. Changes
+// of other tables are not seen by the client, so results depending on them are only dropped after
+// ttl.
+//
+// A ttl of 0 disables the cache.
+func (c *Client) EnableQueryCache(ttl time.Duration) {
+	var rc *resultCache
+	if ttl > 0 {
+		rc = newResultCache(ttl, DefaultQueryCacheSize, DefaultQueryCacheBytes, DefaultMaxCachedResultBytes)
+	}
+
+	c.updater.mu.Lock()
+	defer c.updater.mu.Unlock()
+
+	c.updater.cache = rc
+}
+
+func (u *updater) resultCache() *resultCache {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	return u.cache
+}
+
+// resultCache holds the results of queries, by query and args.
+type resultCache struct {
+	ttl time.Duration
+	// size is the maximum number of results, maxBytes their maximum estimated memory, and maxResult
+	// the maximum estimated memory of a result.
+	size      int
+	maxBytes  int
+	maxResult int
+
+	mu      sync.Mutex
+	entries map[string]*cachedResult
+	bytes   int
+	// generations are incremented with every invalidation of a table, so that results of queries
+	// started before are not cached.
+	generations map[string]uint64
+}
+
+// cachedResult represents the result of a query.
+type cachedResult struct {
+	columns []string
+	rows    [][]driver.Value
+	tables  []string
+	expires time.Time
+	// bytes is the estimated memory of the rows.
+	bytes int
+	// rest are the rows following rows of a result too large to be cached, not read yet.
+	rest *sql.Rows
+}
+
+func newResultCache(ttl time.Duration, size, maxBytes, maxResult int) *resultCache {
+	return &resultCache{
+		ttl:         ttl,
+		size:        size,
+		maxBytes:    maxBytes,
+		maxResult:   maxResult,
+		entries:     make(map[string]*cachedResult),
+		generations: make(map[string]uint64),
+	}
+}
+
+// query returns the cached result of a query, or the result of origin, cached if it is not too
+// large.
+func (rc *resultCache) query(ctx context.Context, q *mquery.QueryStmt, args []interface{},
+	origin func(context.Context, *mquery.QueryStmt, []interface{}) (*sql.Rows, error)) (*sql.Rows, error) {
+	key := cacheKey(q, args)
+	if r := rc.get(key); r != nil {
+		return serveResult(ctx, r)
+	}
+
+	gens := rc.generationsOf(q.GetRequiredTables())
+
+	rs, err := origin(ctx, q, args)
+	if err != nil {
+		return nil, err
+	}
+	r, err := readResult(rs, rc.maxResult)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.rest == nil {
+		r.tables = q.GetRequiredTables()
+		rc.put(key, r, gens)
+	}
+
+	return serveResult(ctx, r)
+}
+
+func cacheKey(q *mquery.QueryStmt, args []interface{}) string {
+	return fmt.Sprintf("%s %#v", strings.Join(strings.Fields(q.SQL()), " "), args)
+}
+
+// readResult reads the rows of a result. If max is not 0 and the rows read exceed max bytes, it
+// stops reading, and the rows not read yet are left in the rest of the result.
+func readResult(rs *sql.Rows, max int) (r *cachedResult, err error) {
+	defer func() {
+		if r == nil || r.rest == nil {
+			rs.Close()
+		}
+	}()
+
+	cols, err := rs.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of result: %w", err)
+	}
+
+	r = &cachedResult{columns: cols}
+	for rs.Next() {
+		vs := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range vs {
+			ptrs[i] = &vs[i]
+		}
+		if err := rs.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("failed to read result: %w", err)
+		}
+
+		row := make([]driver.Value, len(cols))
+		for i, v := range vs {
+			row[i] = v
+			r.bytes += valueBytes(v)
+		}
+		r.rows = append(r.rows, row)
+
+		if max > 0 && r.bytes > max {
+			r.rest = rs
+			return r, nil
+		}
+	}
+	if err := rs.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read result: %w", err)
+	}
+
+	return r, nil
+}
+
+// valueBytes estimates the memory of a value of a result.
+func valueBytes(v interface{}) int {
+	switch v := v.(type) {
+	case []byte:
+		return len(v) + 24
+	case string:
+		return len(v) + 16
+	default:
+		return 16
+	}
+}
+
+func (rc *resultCache) get(key string) *cachedResult {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	r, ok := rc.entries[key]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(r.expires) {
+		rc.remove(key)
+		return nil
+	}
+	return r
+}
+
+// put caches a result, unless a table it depends on was invalidated since gens, or it is too large.
+func (rc *resultCache) put(key string, r *cachedResult, gens map[string]uint64) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	for t, g := range gens {
+		if rc.generations[t] != g {
+			return
+		}
+	}
+	if r.bytes > rc.maxResult || r.bytes > rc.maxBytes {
+		return
+	}
+
+	rc.remove(key)
+	rc.evict(r.bytes)
+	r.expires = time.Now().Add(rc.ttl)
+	rc.entries[key] = r
+	rc.bytes += r.bytes
+}
+
+// evict drops expired results, then the results expiring first until another result of the given
+// estimated memory fits. rc.mu must be held.
+func (rc *resultCache) evict(bytes int) {
+	now := time.Now()
+	for k, r := range rc.entries {
+		if now.After(r.expires) {
+			rc.remove(k)
+		}
+	}
+
+	for len(rc.entries) > 0 && (len(rc.entries) >= rc.size || rc.bytes+bytes > rc.maxBytes) {
+		var first string
+		for k, r := range rc.entries {
+			if first == "" || r.expires.Before(rc.entries[first].expires) {
+				first = k
+			}
+		}
+		rc.remove(first)
+	}
+}
+
+// remove drops a result. rc.mu must be held.
+func (rc *resultCache) remove(key string) {
+	if r, ok := rc.entries[key]; ok {
+		rc.bytes -= r.bytes
+		delete(rc.entries, key)
+	}
+}
+
+func (rc *resultCache) generationsOf(tables []string) map[string]uint64 {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	gens := make(map[string]uint64, len(tables))
+	for _, t := range tables {
+		gens[t] = rc.generations[t]
+	}
+	return gens
+}
+
+// invalidate drops the results depending on a table. It is a no-op on a nil cache.
+func (rc *resultCache) invalidate(table string) {
+	if rc == nil {
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.generations[table]++
+	for k, r := range rc.entries {
+		for _, t := range r.tables {
+			if t == table {
+				rc.remove(k)
+				break
+			}
+		}
+	}
+}
+
+// resultsDB serves results read already, as *sql.Rows cannot be created otherwise.
+//nolint // Used by serveResult.
+var resultsDB = sql.OpenDB(cacheConnector{})
+
+// serveResult returns the rows of a result read already, followed by its rest if any. Cached
+// results could be served to any number of callers at once.
+func serveResult(ctx context.Context, r *cachedResult) (*sql.Rows, error) {
+	return resultsDB.QueryContext(ctx, "", r)
+}
+
+// cacheConnector connects to results read already. Queries have the result to serve as their only
+// arg.
+type cacheConnector struct{}
+
+func (c cacheConnector) Connect(context.Context) (driver.Conn, error) {
+	return &cacheConn{}, nil
+}
+
+func (c cacheConnector) Driver() driver.Driver {
+	return cacheDriver{}
+}
+
+type cacheDriver struct{}
+
+func (cacheDriver) Open(string) (driver.Conn, error) {
+	return nil, fmt.Errorf("cache driver must be used with a connector")
+}
+
+type cacheConn struct{}
+
+func (c *cacheConn) QueryContext(ctx context.Context, _ string, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("cache driver only serves results read already")
+	}
+	r := args[0].Value.(*cachedResult) //nolint // Set by serveResult.
+
+	return &cacheRows{r: r}, nil
+}
+
+// CheckNamedValue passes results to QueryContext as is.
+func (c *cacheConn) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+func (c *cacheConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("prepare method not implemented")
+}
+
+func (c *cacheConn) Close() error {
+	return nil
+}
+
+func (c *cacheConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("transactions are not supported")
+}
+
+type cacheRows struct {
+	r *cachedResult
+	i int
+}
+
+func (r *cacheRows) Columns() []string {
+	return r.r.columns
+}
+
+func (r *cacheRows) Close() error {
+	if r.r.rest != nil {
+		return r.r.rest.Close()
+	}
+	return nil
+}
+
+func (r *cacheRows) Next(dest []driver.Value) error {
+	if r.i < len(r.r.rows) {
+		copy(dest, r.r.rows[r.i])
+		r.i++
+		return nil
+	}
+
+	rest := r.r.rest
+	if rest == nil {
+		return io.EOF
+	}
+	if !rest.Next() {
+		if err := rest.Err(); err != nil {
+			return fmt.Errorf("failed to read result: %w", err)
+		}
+		return io.EOF
+	}
+
+	vs := make([]interface{}, len(dest))
+	ptrs := make([]interface{}, len(dest))
+	for i := range vs {
+		ptrs[i] = &vs[i]
+	}
+	if err := rest.Scan(ptrs...); err != nil {
+		return fmt.Errorf("failed to read result: %w", err)
+	}
+	for i, v := range vs {
+		dest[i] = v
+	}
+
+	return nil
+}
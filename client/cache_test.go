@@ -0,0 +1,224 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	mquery "github.com/hojulian/microdb/query"
+)
+
+func TestResultCache(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		// ttl, maxBytes and maxResult default to a minute and the sizes of the cache of the client.
+		maxBytes  int
+		maxResult int
+		// between runs between the queries.
+		between    func(rc *resultCache)
+		wantOrigin int32
+		wantCached int
+	}{
+		{
+			name:       "hit",
+			wantOrigin: 1,
+			wantCached: 1,
+		},
+		{
+			name:       "miss after invalidation",
+			between:    func(rc *resultCache) { rc.invalidate("test") },
+			wantOrigin: 2,
+			wantCached: 1,
+		},
+		{
+			name:       "invalidation of other table",
+			between:    func(rc *resultCache) { rc.invalidate("other") },
+			wantOrigin: 1,
+			wantCached: 1,
+		},
+		{
+			name:       "expired",
+			ttl:        10 * time.Millisecond,
+			between:    func(*resultCache) { time.Sleep(20 * time.Millisecond) },
+			wantOrigin: 2,
+			wantCached: 1,
+		},
+		{
+			name:       "result too large",
+			maxResult:  100,
+			wantOrigin: 2,
+			wantCached: 0,
+		},
+		{
+			name:       "cache full",
+			maxBytes:   100,
+			wantOrigin: 2,
+			wantCached: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testOrigin(t, 10)
+			ttl, maxBytes, maxResult := tt.ttl, tt.maxBytes, tt.maxResult
+			if ttl == 0 {
+				ttl = time.Minute
+			}
+			if maxBytes == 0 {
+				maxBytes = DefaultQueryCacheBytes
+			}
+			if maxResult == 0 {
+				maxResult = DefaultMaxCachedResultBytes
+			}
+			rc := newResultCache(ttl, DefaultQueryCacheSize, maxBytes, maxResult)
+			q, origin, calls := cacheQuery(t, db)
+
+			want := rows(t, db, "test", 3)
+
+			// Results too large to be cached are still served in full.
+			assert.Equal(t, want, cachedRows(t, rc, q, origin))
+			if tt.between != nil {
+				tt.between(rc)
+			}
+			assert.Equal(t, want, cachedRows(t, rc, q, origin))
+
+			assert.Equal(t, tt.wantOrigin, atomic.LoadInt32(calls))
+			assert.Len(t, rc.entries, tt.wantCached)
+		})
+	}
+}
+
+func TestResultCacheEviction(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		maxBytes int
+	}{
+		{name: "too many results", size: 2, maxBytes: 100},
+		{name: "too many bytes", size: 10, maxBytes: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := newResultCache(time.Minute, tt.size, tt.maxBytes, tt.maxBytes)
+			for _, k := range []string{"a", "b", "c"} {
+				rc.put(k, &cachedResult{bytes: 40}, nil)
+				// The result expiring first is evicted.
+				time.Sleep(time.Millisecond)
+			}
+
+			assert.Nil(t, rc.get("a"))
+			assert.NotNil(t, rc.get("b"))
+			assert.NotNil(t, rc.get("c"))
+			assert.Equal(t, 80, rc.bytes)
+		})
+	}
+}
+
+func TestResultCacheConcurrentInvalidation(t *testing.T) {
+	db := testOrigin(t, 10)
+	rc := newResultCache(time.Minute, DefaultQueryCacheSize, DefaultQueryCacheBytes, DefaultMaxCachedResultBytes)
+	q, origin, _ := cacheQuery(t, db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			rc.invalidate("test")
+		}
+	}()
+
+	var queries sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		queries.Add(1)
+		go func() {
+			defer queries.Done()
+			for j := 0; j < 100; j++ {
+				rs, err := rc.query(ctx, q, nil, origin)
+				if !assert.NoError(t, err) {
+					return
+				}
+				n := 0
+				for rs.Next() {
+					n++
+				}
+				assert.NoError(t, rs.Err())
+				rs.Close()
+				assert.Equal(t, 10, n)
+			}
+		}()
+	}
+
+	queries.Wait()
+	cancel()
+	wg.Wait()
+}
+
+// testOrigin returns a data origin with n rows in table test.
+func testOrigin(t *testing.T, n int) *sql.DB {
+	t.Helper()
+
+	_, db := testRegistry(t)
+	for i := 0; i < n; i++ {
+		insert(t, db, "test", 3, [][]interface{}{{int64(i), strings.Repeat("a", 10), int64(i)}})
+	}
+	return db
+}
+
+// cacheQuery returns a query of table test, and an origin function counting its calls.
+func cacheQuery(t *testing.T, db *sql.DB) (*mquery.QueryStmt, func(context.Context, *mquery.QueryStmt,
+	[]interface{}) (*sql.Rows, error), *int32) {
+	t.Helper()
+
+	q, err := mquery.Query("SELECT * FROM test")
+	if err != nil {
+		t.Fatalf("failed to parse query: %s", err)
+	}
+
+	var calls int32
+	origin := func(ctx context.Context, q *mquery.QueryStmt, args []interface{}) (*sql.Rows, error) {
+		atomic.AddInt32(&calls, 1)
+		return db.QueryContext(ctx, q.SQL(), args...)
+	}
+	return q, origin, &calls
+}
+
+// cachedRows reads the rows of a query through a cache.
+func cachedRows(t *testing.T, rc *resultCache, q *mquery.QueryStmt,
+	origin func(context.Context, *mquery.QueryStmt, []interface{}) (*sql.Rows, error)) [][]interface{} {
+	t.Helper()
+
+	rs, err := rc.query(context.Background(), q, nil, origin)
+	if err != nil {
+		t.Fatalf("failed to query: %s", err)
+	}
+	defer rs.Close()
+
+	var r [][]interface{}
+	for rs.Next() {
+		vs := make([]interface{}, 3)
+		ptrs := make([]interface{}, 3)
+		for i := range vs {
+			ptrs[i] = &vs[i]
+		}
+		if err := rs.Scan(ptrs...); err != nil {
+			t.Fatalf("failed to read row: %s", err)
+		}
+		r = append(r, vs)
+	}
+	if err := rs.Err(); err != nil {
+		t.Fatalf("failed to read rows: %s", err)
+	}
+	return r
+}
@@ -5,11 +5,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sync"
+	"time"
 
 	// Register local database driver.
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/nats-io/stan.go"
-	"google.golang.org/protobuf/proto"
 
 	pb "github.com/hojulian/microdb/internal/proto"
 	"github.com/hojulian/microdb/microdb"
@@ -18,54 +19,131 @@ import (
 
 // Client represents a microDB client.
 type Client struct {
-	sc     stan.Conn
+	reg    *microdb.Registry
+	nats   *natsSession
 	mdb    *sql.DB
+	mu     sync.RWMutex
 	tables map[string]stan.Subscription
+
+	updater    *updater
+	writeRetry writeRetry
+	cred       *credentials
+	// federatedRowLimit is the maximum number of rows of a table read by a federated query.
+	federatedRowLimit int
 }
 
-// Connect creates a microDB client.
+// Connect creates a microDB client using the default data origin registry.
 func Connect(natsHost, natsPort, natsClientID, natsClusterID string, tables ...string) (*Client, error) {
-	sc, err := microdb.NATSConn(natsHost, natsPort, natsClusterID, natsClientID, nil, nil)
+	return ConnectWithRegistry(microdb.DefaultRegistry(), natsHost, natsPort, natsClientID, natsClusterID, tables...)
+}
+
+// ConnectWithRegistry creates a microDB client that looks up data origins in the given registry.
+func ConnectWithRegistry(
+	reg *microdb.Registry,
+	natsHost, natsPort, natsClientID, natsClusterID string,
+	tables ...string,
+) (*Client, error) {
+	ts := make([]Table, 0, len(tables))
+	for _, t := range tables {
+		ts = append(ts, Table{Name: t})
+	}
+
+	return ConnectWithTables(reg, natsHost, natsPort, natsClientID, natsClusterID, ts...)
+}
+
+func connect(reg *microdb.Registry, natsHost, natsPort, natsClientID, natsClusterID string) (*Client, error) {
+	ns, err := dialSession(natsHost, natsPort, natsClusterID, natsClientID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+		return nil, err
 	}
 
-	mdb, err := sql.Open("sqlite3", "file::memory:?cache=shared&mode=memory&_journal=memory&_cache_size=-64000")
-	mdb.SetConnMaxLifetime(-1)
+	mdb, err := sql.Open("sqlite3", localDSN())
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to local database: %w", err)
 	}
+	mdb.SetConnMaxLifetime(-1)
 
 	c := &Client{
-		sc:     sc,
+		reg:    reg,
+		nats:   ns,
 		mdb:    mdb,
 		tables: make(map[string]stan.Subscription),
+
+		updater:    newUpdater(reg, mdb, ns),
+		writeRetry: defaultWriteRetry,
+
+		federatedRowLimit: DefaultFederatedRowLimit,
 	}
-	if err := c.subscribe(tables); err != nil {
-		return nil, fmt.Errorf("failed to initialize microDB client: %w", err)
-	}
+	ns.onLost = c.updater.stale
+	ns.onReconnect = c.resubscribe
 
 	return c, nil
 }
 
-func (c *Client) subscribe(tables []string) error {
+func (c *Client) subscribe(tables []Table) error {
 	for _, t := range tables {
-		if err := createTable(c.mdb, t); err != nil {
-			return fmt.Errorf("failed to create table locally: %w", err)
+		if err := c.addTable(t); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		sub, err := subscribeTable(t, c.sc, c.mdb)
-		if err != nil {
-			return fmt.Errorf("failed to subscribe to table: %w", err)
-		}
+// addTable creates a local table and subscribes to its updates.
+func (c *Client) addTable(t Table) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.tables[t.Name]; ok {
+		return fmt.Errorf("table is already subscribed, got: %s", t.Name)
+	}
+
+	do, err := c.reg.GetDataOrigin(t.Name)
+	if err != nil {
+		return fmt.Errorf("failed to get data origin for table: %w", err)
+	}
+
+	if err := createTable(c.reg, c.mdb, t.Name); err != nil {
+		return fmt.Errorf("failed to create table locally: %w", err)
+	}
 
-		c.tables[t] = sub
+	sub, err := c.subscribeLocalTable(t)
+	if err == nil {
+		if err = c.updater.subscribeReplay(t.Name, c.nats.natsConn()); err != nil {
+			sub.Unsubscribe() //nolint // Best effort clean up.
+		}
+	}
+	if err != nil {
+		c.updater.remove(t.Name)
+		c.mdb.Exec(fmt.Sprintf("DROP TABLE %s", quoteIdent(t.Name))) //nolint // Best effort clean up.
+		return err
 	}
+
+	c.tables[t.Name] = sub
+	go c.updater.probe(t.Name, do.ReadTopic())
+
 	return nil
 }
 
-func createTable(db *sql.DB, table string) error {
-	tq, err := microdb.LocalTableQuery(table)
+func (c *Client) subscribeLocalTable(t Table) (stan.Subscription, error) {
+	if err := c.updater.setColumns(t.Name, t.Columns); err != nil {
+		return nil, err
+	}
+
+	if err := c.updater.setFilter(t.Name, t.Filter); err != nil {
+		return nil, err
+	}
+
+	sub, err := subscribeTable(c.reg, t.Name, c.nats.conn(), c.updater.handler(t.Name), stan.DeliverAllAvailable())
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to table: %w", err)
+	}
+
+	return sub, nil
+}
+
+func createTable(reg *microdb.Registry, db *sql.DB, table string) error {
+	tq, err := reg.LocalTableQuery(table)
 	if err != nil {
 		return fmt.Errorf("failed to get table schema query: %w", err)
 	}
@@ -78,14 +156,14 @@ func createTable(db *sql.DB, table string) error {
 	return nil
 }
 
-func subscribeTable(table string, sc stan.Conn, db *sql.DB) (stan.Subscription, error) {
-	do, err := microdb.GetDataOrigin(table)
+func subscribeTable(reg *microdb.Registry, table string, sc stan.Conn, handler stan.MsgHandler,
+	start stan.SubscriptionOption) (stan.Subscription, error) {
+	do, err := reg.GetDataOrigin(table)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get data origin for table: %w", err)
 	}
-	handler := tableHandler(db, table)
 
-	sub, err := sc.Subscribe(do.ReadTopic(), handler, stan.DeliverAllAvailable())
+	sub, err := sc.Subscribe(do.ReadTopic(), handler, start)
 	if err != nil {
 		return nil, fmt.Errorf("failed to subscribe to table updates: %w", err)
 	}
@@ -93,44 +171,6 @@ func subscribeTable(table string, sc stan.Conn, db *sql.DB) (stan.Subscription,
 	return sub, nil
 }
 
-func tableHandler(db *sql.DB, table string) stan.MsgHandler {
-	return func(m *stan.Msg) {
-		var ru pb.RowUpdate
-
-		if err := proto.Unmarshal(m.Data, &ru); err != nil {
-			panic(fmt.Errorf("failed to parse row update: %w", err))
-		}
-
-		iq, err := microdb.InsertQuery(table)
-		if err != nil {
-			panic(fmt.Errorf("failed to get insert query: %w", err))
-		}
-
-		tx, err := db.Begin()
-		if err != nil {
-			panic(fmt.Errorf("failed to create update transaction: %w", err))
-		}
-
-		r, err := tx.Exec(iq, pb.UnmarshalValues(ru.GetRow())...)
-		if err != nil {
-			derr := fmt.Errorf("failed to update local databse for table %s: %w, got: %s",
-				table, err, ru.String())
-			if rerr := tx.Rollback(); rerr != nil {
-				panic(fmt.Errorf("failed to rollback transaction: %w for error: %s",
-					rerr, derr.Error()))
-			}
-		}
-
-		if ra, err := r.RowsAffected(); ra == 0 || err != nil {
-			panic(fmt.Errorf("failed to update table: %w or no rows affected", err))
-		}
-
-		if err := tx.Commit(); err != nil {
-			panic(fmt.Errorf("failed commit update to table: %w", err))
-		}
-	}
-}
-
 // Query executes a query that returns rows, typically a SELECT. The args are for any placeholder
 // parameters in the query.
 func (c *Client) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
@@ -142,9 +182,23 @@ func (c *Client) Query(ctx context.Context, query string, args ...interface{}) (
 		return nil, fmt.Errorf("unsupported query type: %w", err)
 	}
 
+	q = route(ctx, q)
+	switch {
+	case q.IsForced():
+		// Queries forced to the local database are only sent to data origin if they read tables
+		// or columns that do not exist locally.
+		if q.GetDestinationType() == mquery.DestinationTypeLocal && !c.hasAllRequiredTable(q) {
+			q = q.OnOrigin()
+		}
+
 	// Check if it is able to be executed locally
-	if !c.containsAllRequiredTable(q.GetRequiredTables()) {
-		// If not, force the query to data origin
+	case !c.containsAllRequiredTable(q.GetRequiredTables()) || !c.updater.covers(q) ||
+		!c.updater.hasColumns(q):
+		// If not, join the tables read from each source in-process, or force the query to data
+		// origin if they share one.
+		if c.federated(q) {
+			return c.queryFederated(ctx, q, args)
+		}
 		q = q.OnOrigin()
 	}
 
@@ -157,25 +211,32 @@ func (c *Client) Query(ctx context.Context, query string, args ...interface{}) (
 		return rs, nil
 
 	case mquery.DestinationTypeOrigin:
-		d, err := microdb.GetDataOrigin(q.GetDestinationTable())
-		if err != nil {
-			return nil, fmt.Errorf("failed to get data origin for table: %w", err)
+		if rc := c.updater.resultCache(); rc != nil {
+			return rc.query(ctx, q, args, c.queryOrigin)
 		}
+		return c.queryOrigin(ctx, q, args)
+	}
 
-		db, err := d.GetDB()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get database connector for data origin: %w", err)
-		}
+	return nil, fmt.Errorf("unsupported destination type")
+}
 
-		rs, err := db.QueryContext(ctx, q.SQL(), args)
-		if err != nil {
-			return nil, fmt.Errorf("failed to query data origin database: %w", err)
-		}
+func (c *Client) queryOrigin(ctx context.Context, q *mquery.QueryStmt, args []interface{}) (*sql.Rows, error) {
+	d, err := c.reg.GetDataOrigin(originTable(q))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data origin for table: %w", err)
+	}
 
-		return rs, nil
+	db, err := d.GetDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connector for data origin: %w", err)
 	}
 
-	return nil, fmt.Errorf("unsupported destination type")
+	rs, err := db.QueryContext(ctx, q.SQL(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query data origin database: %w", err)
+	}
+
+	return rs, nil
 }
 
 // Execute executes a query without returning any rows. The args are for any placeholder parameters
@@ -194,38 +255,61 @@ func (c *Client) Execute(ctx context.Context, query string, args ...interface{})
 		Args:  pb.MarshalValues(args),
 	}
 
-	p, err := proto.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal write request: %w", err)
-	}
-
 	dest := q.GetDestinationTable()
-	do, err := microdb.GetDataOrigin(dest)
+	do, err := c.reg.GetDataOrigin(dest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get data origin for table: %w", err)
 	}
 
 	// Forward to querier directly, it will figure out the type conversion.
-	msg, err := c.sc.NatsConn().RequestWithContext(ctx, do.WriteTopic(), p)
+	res, err := requestWrite(ctx, c.nats.natsConn(), do.WriteTopic(), req, c.writeRetry, c.cred)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %w", err)
+		return nil, err
 	}
 
-	var res pb.WriteQueryReply
-	if err := proto.Unmarshal(msg.Data, &res); err != nil {
-		return nil, fmt.Errorf("failed to parse reply: %w", err)
-	}
+	return res.GetResult(), nil
+}
 
-	if !res.GetOk() {
-		return nil, fmt.Errorf("failed to execute query: %s", res.GetMsg())
+// SetWriteRetry sets how long Execute waits for a querier to reply before sending the write again,
+// and how many times it is sent at most. The write is executed only once, even if sent again.
+//
+// A timeout of 0 only waits for the context of Execute, and an attempts of 1 disables retries.
+func (c *Client) SetWriteRetry(attemptTimeout time.Duration, attempts int) {
+	c.writeRetry = writeRetry{attemptTimeout: attemptTimeout, attempts: attempts}
+}
+
+// SetCredentials signs the writes of the client as the client id with its key, so that queriers
+// enforcing access configs could authenticate it.
+func (c *Client) SetCredentials(id string, key []byte) {
+	c.cred = &credentials{id: id, key: key}
+}
+
+// originTable returns the table whose data origin executes a query, i.e. the table it writes to, or
+// the first table it reads.
+func originTable(q *mquery.QueryStmt) string {
+	if t := q.GetDestinationTable(); t != "" {
+		return t
+	}
+	if ts := q.GetRequiredTables(); len(ts) > 0 {
+		return ts[0]
 	}
+	return ""
+}
 
-	return res.GetResult(), nil
+// hasAllRequiredTable reports whether every table and column a query reads exists locally, whatever
+// the state of the tables.
+func (c *Client) hasAllRequiredTable(q *mquery.QueryStmt) bool {
+	for _, t := range q.GetRequiredTables() {
+		if !c.subscribed(t) {
+			return false
+		}
+	}
+	return c.updater.hasColumns(q)
 }
 
 func (c *Client) containsAllRequiredTable(ts []string) bool {
 	for _, t := range ts {
-		if _, ok := c.tables[t]; !ok {
+		if !c.subscribed(t) || !c.updater.healthy(t) || !c.updater.live(t) {
 			return false
 		}
 	}
@@ -234,14 +318,22 @@ func (c *Client) containsAllRequiredTable(ts []string) bool {
 
 // Close unsubscribes database changes and closes its local database.
 func (c *Client) Close() error {
+	c.updater.close()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	for _, s := range c.tables {
+		// Subscriptions of a lost connection are gone already.
+		if c.nats.err() != nil {
+			break
+		}
 		if err := s.Unsubscribe(); err != nil {
 			return fmt.Errorf("failed to unsubscribe table: %w", err)
 		}
 	}
 
-	if err := c.sc.Close(); err != nil {
-		return fmt.Errorf("failed to close nats connection: %w", err)
+	if err := c.nats.close(); err != nil {
+		return err
 	}
 
 	if err := c.mdb.Close(); err != nil {
@@ -6,8 +6,10 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/hojulian/microdb/client"
+	pb "github.com/hojulian/microdb/internal/proto"
 	"github.com/hojulian/microdb/internal/test"
 	"github.com/hojulian/microdb/microdb"
 )
@@ -125,3 +127,236 @@ func setup(t *testing.T) {
 		t.Fatalf("failed to add test data origin: %s", err)
 	}
 }
+
+func TestExecuteRetry(t *testing.T) {
+	setup(t)
+
+	sc, err := microdb.NATSConn("127.0.0.1", "4222", "nats-cluster", "client-retry-test", nil, nil)
+	if err != nil {
+		t.Fatalf("failed to connect to NATS: %s", err)
+	}
+	defer sc.Close()
+
+	do, err := microdb.GetDataOrigin(test.TestTableName)
+	if err != nil {
+		t.Fatalf("failed to get data origin: %s", err)
+	}
+
+	// A plain insert fails if executed twice, as the row already exists.
+	req := &pb.QueryRequest{
+		Query:     `INSERT INTO test (id, string_type) VALUES (?, ?)`,
+		Args:      pb.MarshalValues([]interface{}{444, "test-444"}),
+		RequestId: test.UUID(),
+	}
+	p, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatalf("failed to marshal request: %s", err)
+	}
+
+	var replies []*pb.WriteQueryReply
+	for i := 0; i < 2; i++ {
+		msg, err := sc.NatsConn().Request(do.WriteTopic(), p, requestTimeout)
+		if err != nil {
+			t.Fatalf("failed to send request: %s", err)
+		}
+
+		var res pb.WriteQueryReply
+		assert.Nil(t, proto.Unmarshal(msg.Data, &res))
+		replies = append(replies, &res)
+	}
+
+	assert.True(t, replies[0].GetOk(), "got: %s", replies[0].GetMsg())
+	assert.True(t, proto.Equal(replies[0], replies[1]))
+
+	// Without the request ID, the same insert is a new write.
+	c, err := client.Connect("127.0.0.1", "4222", "client-retry-unit-test", "nats-cluster")
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	defer c.Close()
+
+	ctx, cFunc := context.WithTimeout(context.Background(), requestTimeout)
+	defer cFunc()
+
+	_, err = c.Execute(ctx, req.Query, 444, "test-444")
+	assert.ErrorIs(t, err, client.ErrDuplicateKey)
+	assert.NotErrorIs(t, err, client.ErrRetryable)
+}
+
+func TestExecuteBatch(t *testing.T) {
+	setup(t)
+
+	c, err := client.Connect("127.0.0.1", "4222", "client-batch-unit-test", "nats-cluster")
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	defer c.Close()
+
+	insert := `INSERT INTO test (id, string_type) VALUES (?, ?)`
+
+	testCases := []struct {
+		desc      string
+		stmts     []client.Statement
+		results   int
+		err       error
+		statement int
+	}{
+		{
+			desc: "all statements succeed",
+			stmts: []client.Statement{
+				{Query: insert, Args: []interface{}{555, "test-555"}},
+				{Query: insert, Args: []interface{}{556, "test-556"}},
+			},
+			results: 2,
+		},
+		{
+			desc: "failed statement rolls back the batch",
+			stmts: []client.Statement{
+				{Query: insert, Args: []interface{}{557, "test-557"}},
+				{Query: insert, Args: []interface{}{557, "test-557"}},
+			},
+			err:       client.ErrDuplicateKey,
+			statement: 1,
+		},
+		{
+			desc: "rolled back statement is not executed",
+			stmts: []client.Statement{
+				{Query: insert, Args: []interface{}{557, "test-557"}},
+			},
+			results: 1,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctx, cFunc := context.WithTimeout(context.Background(), requestTimeout)
+			defer cFunc()
+
+			rs, err := c.ExecuteBatch(ctx, tC.stmts)
+			if tC.err != nil {
+				assert.ErrorIs(t, err, tC.err)
+
+				var qerr *client.QueryError
+				if assert.ErrorAs(t, err, &qerr) {
+					assert.Equal(t, tC.statement, qerr.Statement)
+				}
+				return
+			}
+
+			if assert.Nil(t, err) && assert.Len(t, rs, tC.results) {
+				for _, r := range rs {
+					ra, err := r.RowsAffected()
+					assert.Nil(t, err)
+					assert.Equal(t, int64(1), ra)
+				}
+			}
+		})
+	}
+}
+
+func TestTablePolicy(t *testing.T) {
+	setup(t)
+
+	c, err := client.Connect("127.0.0.1", "4222", "client-policy-unit-test", "nats-cluster", test.TestTableName)
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	defer c.Close()
+
+	errs := make(chan *client.UpdateError, 16)
+	c.SetErrorHandler(func(e *client.UpdateError) { errs <- e })
+	c.SetTablePolicy(test.TestTableName, client.PolicyStop)
+
+	sc, err := microdb.NATSConn("127.0.0.1", "4222", "nats-cluster", "client-policy-test", nil, nil)
+	if err != nil {
+		t.Fatalf("failed to connect to NATS: %s", err)
+	}
+	defer sc.Close()
+
+	do, err := microdb.GetDataOrigin(test.TestTableName)
+	if err != nil {
+		t.Fatalf("failed to get data origin: %s", err)
+	}
+
+	// A row update that cannot be parsed.
+	if err := sc.Publish(do.ReadTopic(), []byte{0xff}); err != nil {
+		t.Fatalf("failed to publish row update: %s", err)
+	}
+
+	select {
+	case e := <-errs:
+		assert.Equal(t, test.TestTableName, e.Table)
+		assert.Equal(t, client.PolicyStop, e.Policy)
+	case <-time.After(propagateTime):
+		t.Fatal("timed out waiting for update error")
+	}
+
+	h, err := c.TableHealth(test.TestTableName)
+	if assert.Nil(t, err) {
+		assert.False(t, h.Healthy)
+		assert.NotZero(t, h.Errors)
+		assert.NotNil(t, h.LastError)
+	}
+}
+
+func TestAddRemoveTable(t *testing.T) {
+	setup(t)
+
+	c, err := client.Connect("127.0.0.1", "4222", "client-tables-unit-test", "nats-cluster")
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	defer c.Close()
+
+	ctx, cFunc := context.WithTimeout(context.Background(), requestTimeout)
+	defer cFunc()
+
+	if err := c.AddTable(ctx, client.Table{Name: test.TestTableName}); err != nil {
+		t.Fatalf("failed to add table: %s", err)
+	}
+	assert.NotNil(t, c.AddTable(ctx, client.Table{Name: test.TestTableName}))
+
+	ready, err := c.Ready(test.TestTableName)
+	if assert.Nil(t, err) {
+		select {
+		case <-ready:
+		default:
+			t.Error("table is not ready")
+		}
+	}
+
+	assert.Nil(t, c.RemoveTable(test.TestTableName))
+	assert.NotNil(t, c.RemoveTable(test.TestTableName))
+	_, err = c.Ready(test.TestTableName)
+	assert.NotNil(t, err)
+}
+
+func TestWaitReady(t *testing.T) {
+	setup(t)
+
+	c, err := client.Connect("127.0.0.1", "4222", "client-ready-unit-test", "nats-cluster", test.TestTableName)
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	defer c.Close()
+
+	ctx, cFunc := context.WithTimeout(context.Background(), requestTimeout)
+	defer cFunc()
+
+	if err := c.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to wait for tables: %s", err)
+	}
+
+	h, err := c.TableHealth(test.TestTableName)
+	if assert.Nil(t, err) {
+		assert.Equal(t, client.SyncLive, h.State)
+	}
+
+	ss, err := c.Stats()
+	if assert.Nil(t, err) && assert.Len(t, ss, 1) {
+		assert.Equal(t, test.TestTableName, ss[0].Table)
+		assert.Equal(t, client.SyncLive, ss[0].State)
+		assert.True(t, ss[0].Subscribed)
+		assert.Zero(t, ss[0].PendingUpdates)
+	}
+}
@@ -10,7 +10,6 @@ import (
 
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/stan.go"
-	"google.golang.org/protobuf/proto"
 
 	pb "github.com/hojulian/microdb/internal/proto"
 	"github.com/hojulian/microdb/microdb"
@@ -23,26 +22,38 @@ var (
 	_ driver.QueryerContext = &Conn{}
 	_ driver.ExecerContext  = &Conn{}
 	_ driver.QueryerContext = &Conn{}
+	_ driver.ConnBeginTx    = &Conn{}
 )
 
 // Conn is a connection to MicroDB system. It is not used concurrently by multiple goroutines.
 //
 // Conn is assumed to be stateful.
 type Conn struct {
-	sc     stan.Conn
-	sqc    driver.Conn
-	tables map[string]stan.Subscription
+	reg     *microdb.Registry
+	nats    *natsSession
+	updater *updater
+	sqc     driver.Conn
+	tables  map[string]stan.Subscription
+
+	cred *credentials
+
+	// tx is the transaction in progress, if any.
+	tx *connTx
 }
 
 // Ping verifies a connection to the database is still alive, establishing a connection if necessary.
+//
+// It fails while the connection to NATS is lost and the driver reconnects; local tables are stale
+// in the meantime, and queries are sent to the data origins.
 func (c *Conn) Ping(ctx context.Context) error {
-	switch c.sc.NatsConn().Status() {
-	case nats.CONNECTED:
-		return nil
-	case nats.CLOSED:
+	if err := c.nats.err(); err != nil {
+		return fmt.Errorf("%w: reconnecting after: %s", ErrNATSError, err)
+	}
+
+	if c.nats.natsConn().Status() != nats.CONNECTED {
 		return ErrNATSError
 	}
-	return ErrNATSError
+	return nil
 }
 
 // Prepare returns a prepared statement, bound to this connection.
@@ -54,10 +65,22 @@ func (c *Conn) Prepare(query string) (driver.Stmt, error) {
 
 // Begin starts and returns a new transaction.
 //
-// Note: Not implemented.
 // Deprecated: Drivers should implement ConnBeginTx instead (or additionally).
 func (c *Conn) Begin() (driver.Tx, error) {
-	return nil, fmt.Errorf("begin method not implemented")
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx starts and returns a new transaction.
+//
+// Writes of the transaction are executed in a single data origin transaction, as a batch, when it
+// is committed. Their results are only available after that.
+func (c *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.tx != nil {
+		return nil, errors.New("transaction already in progress")
+	}
+
+	c.tx = &connTx{c: c}
+	return c.tx, nil
 }
 
 // Close invalidates and potentially stops any current
@@ -96,8 +119,17 @@ func (c *Conn) QueryContext(ctx context.Context, query string, args []driver.Nam
 		return nil, fmt.Errorf("unsupported query type: %w", err)
 	}
 
+	q = route(ctx, q)
+	switch {
+	case q.IsForced():
+		// Queries forced to the local database are only sent to data origin if they read tables
+		// that are not subscribed.
+		if q.GetDestinationType() == mquery.DestinationTypeLocal && !c.hasAllRequiredTable(q) {
+			q = q.OnOrigin()
+		}
+
 	// Check if it is able to be executed locally
-	if !c.containsAllRequiredTable(q.GetRequiredTables()) {
+	case !c.containsAllRequiredTable(q.GetRequiredTables()):
 		// If not, force the query to data origin
 		q = q.OnOrigin()
 	}
@@ -111,7 +143,7 @@ func (c *Conn) QueryContext(ctx context.Context, query string, args []driver.Nam
 		return rs, nil
 
 	case mquery.DestinationTypeOrigin:
-		d, err := microdb.GetDataOrigin(q.GetDestinationTable())
+		d, err := c.reg.GetDataOrigin(originTable(q))
 		if err != nil {
 			return nil, fmt.Errorf("failed to get data origin for table: %w", err)
 		}
@@ -148,9 +180,19 @@ func normalizeDriverValues(ds []driver.NamedValue) []interface{} {
 	return is
 }
 
+// hasAllRequiredTable reports whether every table a query reads is subscribed, whatever its state.
+func (c *Conn) hasAllRequiredTable(q *mquery.QueryStmt) bool {
+	for _, t := range q.GetRequiredTables() {
+		if _, ok := c.updater.tableHealth(t); !ok {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *Conn) containsAllRequiredTable(ts []string) bool {
 	for _, t := range ts {
-		if _, ok := c.tables[t]; !ok {
+		if !c.updater.healthy(t) || !c.updater.live(t) {
 			return false
 		}
 	}
@@ -168,32 +210,28 @@ func (c *Conn) ExecContext(ctx context.Context, query string, args []driver.Name
 		return nil, errors.New("for select query, please use QueryContext")
 	}
 
+	if c.tx != nil {
+		r := &pendingResult{}
+		c.tx.stmts = append(c.tx.stmts, &pb.Statement{
+			Query: q.SQL(),
+			Args:  pb.MarshalDriverValues(args),
+		})
+		c.tx.results = append(c.tx.results, r)
+		return r, nil
+	}
+
 	req := &pb.QueryRequest{
 		Query: q.SQL(),
 		Args:  pb.MarshalDriverValues(args),
 	}
 
-	p, err := proto.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal write request: %w", err)
-	}
-
 	dest := q.GetDestinationTable()
 	destTopic := fmt.Sprintf("%s_write", dest)
 
 	// Forward to querier directly, it will figure out the type conversion.
-	msg, err := c.sc.NatsConn().RequestWithContext(ctx, destTopic, p)
+	res, err := requestWrite(ctx, c.nats.natsConn(), destTopic, req, defaultWriteRetry, c.cred)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %w", err)
-	}
-
-	var res pb.WriteQueryReply
-	if err := proto.Unmarshal(msg.Data, &res); err != nil {
-		return nil, fmt.Errorf("failed to parse reply: %w", err)
-	}
-
-	if !res.GetOk() {
-		return nil, fmt.Errorf("failed to execute query: %s", res.GetMsg())
+		return nil, err
 	}
 
 	return res.GetResult(), nil
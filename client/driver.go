@@ -1,10 +1,13 @@
 package client //nolint // Package comment located in a different file.
 
 import (
+	"bytes"
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"io/ioutil"
 	"strings"
+	"sync"
 
 	"github.com/mattn/go-sqlite3"
 	"github.com/nats-io/stan.go"
@@ -18,23 +21,37 @@ func init() {
 }
 
 // Driver is the MicroDB driver that implements database/sql/driver.
+//
+// The driver registered as "microdb" looks up data origins in the default registry. Drivers of other
+// registries are created with NewDriver, and registered under another name.
 type Driver struct {
 	cfg *driverCfg
 
 	initialized bool
+	reg         *microdb.Registry
+	dsn         string
 	drv         *sqlite3.SQLiteDriver
 	db          *sql.DB
-	sc          stan.Conn
+	nats        *natsSession
+	mu          sync.Mutex
 	tables      map[string]stan.Subscription
+	updater     *updater
+}
+
+// NewDriver creates a MicroDB driver that looks up data origins in the given registry, to be
+// registered with sql.Register.
+func NewDriver(reg *microdb.Registry) *Driver {
+	return &Driver{reg: reg}
 }
 
 type driverCfg struct {
-	dsn           string
 	natsClientID  string
 	natsClusterID string
 	natsHost      string
 	natsPort      string
 	tables        []string
+	authClientID  string
+	authKeyFile   string
 }
 
 // Open returns a new connection to the database.
@@ -43,6 +60,9 @@ type driverCfg struct {
 // dsn format:
 //    natsClientID=... natsHost=... natsPort=... tables=...,...
 //
+// Writes are signed if the dsn also sets authClientID=... and authKeyFile=..., the path of a file
+// holding the key of the client, see Client.SetCredentials.
+//
 // Open may return a cached connection (one previously
 // closed), but doing so is unnecessary; the sql package
 // maintains a pool of idle connections for efficient re-use.
@@ -64,23 +84,29 @@ func (d *Driver) Open(name string) (driver.Conn, error) {
 		}
 	}
 
-	sqc, err := d.drv.Open(d.cfg.dsn)
+	sqc, err := d.drv.Open(d.dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to local sqlite3: %w", err)
 	}
 
+	cred, err := d.cfg.credentials()
+	if err != nil {
+		return nil, err
+	}
+
 	return &Conn{
-		sc:  d.sc,
-		sqc: sqc,
+		reg:     d.reg,
+		nats:    d.nats,
+		updater: d.updater,
+		sqc:     sqc,
+		cred:    cred,
 	}, nil
 }
 
 func parseDSN(dsn string) (*driverCfg, error) {
 	// dsn format:
 	//    natsClientID=... natsHost=... natsPort=... tables=...,...
-	cfg := &driverCfg{
-		dsn: "file::memory:?cache=shared&mode=memory&_journal=memory&_cache_size=-64000",
-	}
+	cfg := &driverCfg{}
 
 	opts, err := parseDSNMap(dsn)
 	if err != nil {
@@ -117,9 +143,28 @@ func parseDSN(dsn string) (*driverCfg, error) {
 		return nil, fmt.Errorf("missing tables")
 	}
 
+	cfg.authClientID = opts["authClientID"]
+	cfg.authKeyFile = opts["authKeyFile"]
+	if (cfg.authClientID == "") != (cfg.authKeyFile == "") {
+		return nil, fmt.Errorf("authClientID and authKeyFile must be set together")
+	}
+
 	return cfg, nil
 }
 
+func (c *driverCfg) credentials() (*credentials, error) {
+	if c.authClientID == "" {
+		return nil, nil
+	}
+
+	key, err := ioutil.ReadFile(c.authKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client key: %w", err)
+	}
+
+	return &credentials{id: c.authClientID, key: bytes.TrimSpace(key)}, nil
+}
+
 func parseDSNMap(dsn string) (map[string]string, error) {
 	opts := make(map[string]string)
 	kvList := strings.Split(dsn, " ")
@@ -139,27 +184,33 @@ func parseDSNMap(dsn string) (map[string]string, error) {
 
 func (d *Driver) init() error {
 	drv := &sqlite3.SQLiteDriver{}
-	db := sql.OpenDB(dsnConnector{dsn: d.cfg.dsn, driver: drv})
+	dsn := localDSN()
+	db := sql.OpenDB(dsnConnector{dsn: dsn, driver: drv})
 	db.SetConnMaxLifetime(-1)
 
-	sc, err := microdb.NATSConn(
+	ns, err := dialSession(
 		d.cfg.natsHost,
 		d.cfg.natsPort,
 		d.cfg.natsClusterID,
 		d.cfg.natsClientID,
-		nil,
-		nil,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to connect to nats cluster: %w", err)
 	}
 
+	if d.reg == nil {
+		d.reg = microdb.DefaultRegistry()
+	}
+	d.dsn = dsn
 	d.drv = drv
 	d.db = db
-	d.sc = sc
+	d.nats = ns
+	d.updater = newUpdater(d.reg, d.db, d.nats)
+	ns.onLost = d.updater.stale
+	ns.onReconnect = d.resubscribe
 
 	for _, t := range d.cfg.tables {
-		if err := createTable(d.db, t); err != nil {
+		if err := createTable(d.reg, d.db, t); err != nil {
 			return fmt.Errorf("failed to create table: %w", err)
 		}
 
@@ -174,16 +225,23 @@ func (d *Driver) init() error {
 }
 
 func (d *Driver) subscribeTable(table string) error {
-	do, err := microdb.GetDataOrigin(table)
+	do, err := d.reg.GetDataOrigin(table)
 	if err != nil {
 		return fmt.Errorf("failed to get data origin for table: %w", err)
 	}
 
-	sub, err := d.sc.Subscribe(do.ReadTopic(), tableHandler(d.db, table), stan.DeliverAllAvailable())
+	sub, err := d.nats.conn().Subscribe(do.ReadTopic(), d.updater.handler(table), stan.DeliverAllAvailable())
 	if err != nil {
 		return fmt.Errorf("failed to subscribe to nats: %w", err)
 	}
+	if err := d.updater.subscribeReplay(table, d.nats.natsConn()); err != nil {
+		sub.Unsubscribe() //nolint // Best effort clean up.
+		return err
+	}
+	go d.updater.probe(table, do.ReadTopic())
 
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	if d.tables == nil {
 		d.tables = make(map[string]stan.Subscription)
 	}
@@ -191,3 +249,20 @@ func (d *Driver) subscribeTable(table string) error {
 
 	return nil
 }
+
+// resubscribe subscribes to the tables of the driver again with a new connection. The subscriptions
+// of the driver are only replaced once every table is subscribed again.
+func (d *Driver) resubscribe(sc stan.Conn) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	subs, err := d.updater.resubscribeAll(d.reg, d.tables, sc)
+	if err != nil {
+		return err
+	}
+	for t, sub := range subs {
+		d.tables[t] = sub
+	}
+
+	return nil
+}
@@ -1,6 +1,10 @@
 package client //nolint // Package comment located in a different file.
 
-import "errors"
+import (
+	"errors"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+)
 
 // MicroDB errors represents all error values returned by MicroDB client.
 
@@ -11,4 +15,133 @@ var (
 	// ErrLocalDBError represents the client lost connection to local database or it is not ready
 	// for operations yet.
 	ErrLocalDBError = errors.New("local sqlite3 database connection error")
+
+	// ErrInvalidRequest represents a write request the querier could not read.
+	ErrInvalidRequest = errors.New("invalid request")
+
+	// ErrSyntax represents a query that could not be parsed, or refers to unknown tables or
+	// columns.
+	ErrSyntax = errors.New("syntax error")
+
+	// ErrDuplicateKey represents a write violating a primary or unique key.
+	ErrDuplicateKey = errors.New("duplicate key")
+
+	// ErrConstraintViolation represents a write violating any other integrity constraint, e.g. a
+	// foreign key or a non-null column.
+	ErrConstraintViolation = errors.New("constraint violation")
+
+	// ErrDeadlock represents a write rolled back to resolve a deadlock.
+	ErrDeadlock = errors.New("deadlock")
+
+	// ErrLockWaitTimeout represents a write that timed out waiting for a lock.
+	ErrLockWaitTimeout = errors.New("lock wait timeout")
+
+	// ErrUnavailable represents a querier that could not reach its data origin.
+	ErrUnavailable = errors.New("data origin unavailable")
+
+	// ErrOverloaded represents a querier handling too many requests.
+	ErrOverloaded = errors.New("querier overloaded")
+
+	// ErrDeadlineExceeded represents a write whose deadline passed before it completed.
+	ErrDeadlineExceeded = errors.New("deadline exceeded")
+
+	// ErrUnauthenticated represents a write the querier could not authenticate, see
+	// Client.SetCredentials.
+	ErrUnauthenticated = errors.New("unauthenticated")
+
+	// ErrPermissionDenied represents a write the client is not allowed to execute.
+	ErrPermissionDenied = errors.New("permission denied")
+
+	// ErrTooManyRows represents a federated query reading more rows of a table than the limit, see
+	// Client.SetFederatedRowLimit.
+	ErrTooManyRows = errors.New("too many rows")
+
+	// ErrRetryable matches every write error that did not change the data origin, and could
+	// succeed if the write is executed again.
+	ErrRetryable = errors.New("retryable error")
+)
+
+// ErrorCode represents the cause of a failed write.
+type ErrorCode int32
+
+// Write error codes.
+const (
+	CodeUnknown ErrorCode = iota
+	CodeInvalidRequest
+	CodeSyntax
+	CodeDuplicateKey
+	CodeConstraintViolation
+	CodeDeadlock
+	CodeLockWaitTimeout
+	CodeUnavailable
+	CodeOverloaded
+	CodeDeadlineExceeded
+	CodeInternal
+	CodeUnauthenticated
+	CodePermissionDenied
 )
+
+//nolint // Used as a lookup table.
+var codeErrors = map[ErrorCode]error{
+	CodeInvalidRequest:      ErrInvalidRequest,
+	CodeSyntax:              ErrSyntax,
+	CodeDuplicateKey:        ErrDuplicateKey,
+	CodeConstraintViolation: ErrConstraintViolation,
+	CodeDeadlock:            ErrDeadlock,
+	CodeLockWaitTimeout:     ErrLockWaitTimeout,
+	CodeUnavailable:         ErrUnavailable,
+	CodeOverloaded:          ErrOverloaded,
+	CodeDeadlineExceeded:    ErrDeadlineExceeded,
+	CodeUnauthenticated:     ErrUnauthenticated,
+	CodePermissionDenied:    ErrPermissionDenied,
+}
+
+// Retryable reports whether writes failing with the code could succeed if executed again.
+func (c ErrorCode) Retryable() bool {
+	switch c {
+	case CodeDeadlock, CodeLockWaitTimeout, CodeUnavailable, CodeOverloaded:
+		return true
+	default:
+		return false
+	}
+}
+
+// QueryError represents a write that failed at the querier.
+//
+// A QueryError matches the sentinel error of its code with errors.Is, and ErrRetryable if the code
+// is retryable.
+type QueryError struct {
+	Code ErrorCode
+	// Number is the error number of the data origin, if the error comes from it.
+	Number uint32
+	// SQLState is the SQLSTATE of the data origin, if the error comes from it.
+	SQLState string
+	// Statement is the index of the failed statement of a batch, or -1.
+	Statement int
+	Msg       string
+}
+
+// Error returns the error message of the querier.
+func (e *QueryError) Error() string {
+	return e.Msg
+}
+
+// Is reports whether the error matches target.
+func (e *QueryError) Is(target error) bool {
+	if target == ErrRetryable {
+		return e.Code.Retryable()
+	}
+
+	err, ok := codeErrors[e.Code]
+	return ok && err == target
+}
+
+func newQueryError(res *pb.WriteQueryReply) *QueryError {
+	return &QueryError{
+		Code:      ErrorCode(res.GetCode()),
+		Number:    res.GetOriginErrno(),
+		SQLState:  res.GetSqlstate(),
+		Statement: int(res.GetFailedStatement()),
+		Msg:       res.GetMsg(),
+	}
+}
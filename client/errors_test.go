@@ -0,0 +1,69 @@
+package client_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hojulian/microdb/client"
+)
+
+func TestQueryError(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		code      client.ErrorCode
+		err       error
+		retryable bool
+	}{
+		{
+			desc: "duplicate key",
+			code: client.CodeDuplicateKey,
+			err:  client.ErrDuplicateKey,
+		},
+		{
+			desc: "syntax error",
+			code: client.CodeSyntax,
+			err:  client.ErrSyntax,
+		},
+		{
+			desc:      "deadlock",
+			code:      client.CodeDeadlock,
+			err:       client.ErrDeadlock,
+			retryable: true,
+		},
+		{
+			desc:      "overloaded",
+			code:      client.CodeOverloaded,
+			err:       client.ErrOverloaded,
+			retryable: true,
+		},
+		{
+			desc: "permission denied",
+			code: client.CodePermissionDenied,
+			err:  client.ErrPermissionDenied,
+		},
+		{
+			desc: "unknown",
+			code: client.CodeUnknown,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			err := fmt.Errorf("failed to execute query: %w", &client.QueryError{Code: tC.code, Msg: "test"})
+
+			if tC.err != nil {
+				assert.ErrorIs(t, err, tC.err)
+			}
+			assert.False(t, errors.Is(err, client.ErrConstraintViolation))
+			assert.Equal(t, tC.retryable, errors.Is(err, client.ErrRetryable))
+
+			var qerr *client.QueryError
+			if assert.ErrorAs(t, err, &qerr) {
+				assert.Equal(t, tC.code, qerr.Code)
+			}
+		})
+	}
+}
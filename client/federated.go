@@ -0,0 +1,193 @@
+package client //nolint // Package comment located in a different file.
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/hojulian/microdb/microdb"
+	mquery "github.com/hojulian/microdb/query"
+)
+
+// Federated queries.
+
+//nolint // Used for naming the databases of federated queries.
+var federatedSeq uint64
+
+const (
+	// sourceLocal is the source of tables read from the local database.
+	sourceLocal = "local"
+	// DefaultFederatedRowLimit is the maximum number of rows of a table read by a federated query.
+	DefaultFederatedRowLimit = 100000
+)
+
+// SetFederatedRowLimit sets the maximum number of rows of a table read by a federated query, i.e.
+// the rows matching the conditions of the query on that table. Federated queries reading more fail
+// with ErrTooManyRows, rather than copying the whole table.
+//
+// A limit of 0 disables it.
+func (c *Client) SetFederatedRowLimit(n int) {
+	c.federatedRowLimit = n
+}
+
+// federated reports whether a select query reads tables of more than one source, i.e. the local
+// database and data origins.
+func (c *Client) federated(q *mquery.QueryStmt) bool {
+	sources := make(map[string]bool)
+	for _, t := range q.GetRequiredTables() {
+		s, err := c.source(t)
+		if err != nil {
+			return false
+		}
+		sources[s] = true
+	}
+	return len(sources) > 1
+}
+
+// source returns the source a table is read from: the local database if all of its rows and
+// columns are replicated and live, or the connection of its data origin.
+func (c *Client) source(table string) (string, error) {
+	if c.containsAllRequiredTable([]string{table}) && c.updater.complete(table) {
+		return sourceLocal, nil
+	}
+
+	do, err := c.reg.GetDataOrigin(table)
+	if err != nil {
+		return "", fmt.Errorf("failed to get data origin for table: %w", err)
+	}
+	return (&microdb.OriginGroup{Connection: do.Connection}).Key(), nil
+}
+
+// queryFederated executes a select query reading tables of more than one source. The rows of each
+// table are read from its source, filtered by the conditions of the query on the table only, and
+// loaded into a temporary in-memory database, where the query is executed.
+func (c *Client) queryFederated(ctx context.Context, q *mquery.QueryStmt, args []interface{}) (*sql.Rows, error) {
+	name := fmt.Sprintf("file:microdb_federated_%d?mode=memory&cache=shared", atomic.AddUint64(&federatedSeq, 1))
+	db, err := sql.Open("sqlite3", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create federated query database: %w", err)
+	}
+	defer db.Close()
+	// The database is dropped once its last connection is closed.
+	db.SetMaxOpenConns(1)
+
+	loaded := make(map[string]bool)
+	for _, t := range q.GetRequiredTables() {
+		if loaded[t] {
+			continue
+		}
+		loaded[t] = true
+
+		if err := c.loadTable(ctx, db, q, t); err != nil {
+			return nil, err
+		}
+	}
+
+	rs, err := db.QueryContext(ctx, q.SQL(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute federated query: %w", err)
+	}
+	r, err := readResult(rs, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return serveResult(ctx, r)
+}
+
+// loadTable copies the rows of a table read by a query from its source into db. It fails with
+// ErrTooManyRows if there are more than the federated row limit.
+func (c *Client) loadTable(ctx context.Context, db *sql.DB, q *mquery.QueryStmt, table string) error {
+	s, err := c.source(table)
+	if err != nil {
+		return err
+	}
+
+	sq := fmt.Sprintf("SELECT * FROM %s", table)
+	if conds := q.GetTableConditions(table); conds != "" {
+		sq += " WHERE " + conds
+	}
+	if c.federatedRowLimit > 0 {
+		// One more row is read to tell whether there are more than the limit.
+		sq += fmt.Sprintf(" LIMIT %d", c.federatedRowLimit+1)
+	}
+
+	var rs *sql.Rows
+	if s == sourceLocal {
+		rs, err = c.mdb.QueryContext(ctx, sq)
+	} else {
+		var tq *mquery.QueryStmt
+		if tq, err = mquery.Query(sq); err == nil {
+			rs, err = c.queryOrigin(ctx, tq, nil)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read table %s: %w", table, err)
+	}
+	defer rs.Close()
+
+	cols, err := rs.ColumnTypes()
+	if err != nil {
+		return fmt.Errorf("failed to read columns of table %s: %w", table, err)
+	}
+
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = quoteIdent(col.Name())
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (%s)", quoteIdent(table),
+		strings.Join(names, ", "))); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", table, err)
+	}
+
+	iq := fmt.Sprintf("INSERT INTO %s VALUES (%s)", quoteIdent(table),
+		strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", "))
+	vs := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range vs {
+		ptrs[i] = &vs[i]
+	}
+	for n := 1; rs.Next(); n++ {
+		if c.federatedRowLimit > 0 && n > c.federatedRowLimit {
+			return fmt.Errorf("failed to read table %s: %w, more than %d rows match the query", table,
+				ErrTooManyRows, c.federatedRowLimit)
+		}
+		if err := rs.Scan(ptrs...); err != nil {
+			return fmt.Errorf("failed to read row of table %s: %w", table, err)
+		}
+		for i, v := range vs {
+			// MySQL returns text as bytes, which SQLite would not compare equal to strings.
+			if b, ok := v.([]byte); ok && !binary(cols[i].DatabaseTypeName()) {
+				vs[i] = string(b)
+			}
+		}
+
+		if _, err := db.ExecContext(ctx, iq, vs...); err != nil {
+			return fmt.Errorf("failed to load row of table %s: %w", table, err)
+		}
+	}
+	if err := rs.Err(); err != nil {
+		return fmt.Errorf("failed to read rows of table %s: %w", table, err)
+	}
+
+	return nil
+}
+
+func binary(typ string) bool {
+	typ = strings.ToUpper(typ)
+	return strings.Contains(typ, "BLOB") || strings.Contains(typ, "BINARY")
+}
+
+// complete reports whether a local table holds all the rows and columns of its data origin table.
+func (u *updater) complete(table string) bool {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	if _, ok := u.filters[table]; ok {
+		return false
+	}
+	cols, ok := u.columns[table]
+	return !ok || cols.source == nil
+}
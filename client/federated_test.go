@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/nats-io/stan.go"
+	"github.com/stretchr/testify/assert"
+
+	mquery "github.com/hojulian/microdb/query"
+)
+
+func TestLoadTableRowLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		limit   int
+		want    int
+		wantErr error
+	}{
+		{
+			name:  "under limit",
+			query: "SELECT * FROM test",
+			limit: 20,
+			want:  10,
+		},
+		{
+			name:  "at limit",
+			query: "SELECT * FROM test",
+			limit: 10,
+			want:  10,
+		},
+		{
+			name:    "over limit",
+			query:   "SELECT * FROM test",
+			limit:   5,
+			wantErr: ErrTooManyRows,
+		},
+		{
+			name:  "conditions under limit",
+			query: "SELECT * FROM test t WHERE t.age < 5",
+			limit: 5,
+			want:  5,
+		},
+		{
+			name:  "no limit",
+			query: "SELECT * FROM test",
+			want:  10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg, origin := testRegistry(t)
+			for i := 0; i < 10; i++ {
+				insert(t, origin, "test", 3, [][]interface{}{{int64(i), "a", int64(i)}})
+			}
+			c := &Client{reg: reg, tables: map[string]stan.Subscription{}, federatedRowLimit: tt.limit}
+
+			db, err := sql.Open("sqlite3", "file:microdb_load_test?mode=memory&cache=shared")
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer db.Close()
+			db.SetMaxOpenConns(1)
+
+			q, err := mquery.Query(tt.query)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			err = c.loadTable(context.Background(), db, q, "test")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Len(t, rows(t, db, "test", 3), tt.want)
+			}
+		})
+	}
+}
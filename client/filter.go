@@ -0,0 +1,144 @@
+package client //nolint // Package comment located in a different file.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hojulian/microdb/microdb"
+	mquery "github.com/hojulian/microdb/query"
+)
+
+// Partial replication.
+
+// Table represents a table subscribed by a client.
+type Table struct {
+	Name string
+	// Filter is a SQL expression over the columns of the table, e.g. "tenant_id = 42". If set, only
+	// rows matching it are stored locally, and rows updated to no longer match it are deleted.
+	//
+	// Queries reading the table are only executed locally if their WHERE clause includes every
+	// condition of the filter, joined with AND, since the local table is missing the other rows.
+	// Other queries are sent to the data origin. See query.Filter for the supported expressions.
+	Filter string
+	// Columns are the columns of the table stored locally, which must include its primary key. If
+	// empty, all columns are stored.
+	//
+	// Queries referring to other columns, or to all columns with "*", are sent to the data origin.
+	Columns []string
+}
+
+// ConnectWithTables creates a microDB client that looks up data origins in the given registry, and
+// subscribes to tables with their filters.
+func ConnectWithTables(
+	reg *microdb.Registry,
+	natsHost, natsPort, natsClientID, natsClusterID string,
+	tables ...Table,
+) (*Client, error) {
+	c, err := connect(reg, natsHost, natsPort, natsClientID, natsClusterID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.subscribe(tables); err != nil {
+		return nil, fmt.Errorf("failed to initialize microDB client: %w", err)
+	}
+
+	return c, nil
+}
+
+// TableFilter returns the filter of a subscribed table, or nil if all of its rows are replicated.
+func (c *Client) TableFilter(table string) (*mquery.Filter, error) {
+	if !c.subscribed(table) {
+		return nil, fmt.Errorf("table is not subscribed, got: %s", table)
+	}
+
+	return c.updater.filter(table), nil
+}
+
+// setFilter parses the filter of a local table, and checks that the table has the columns it refers
+// to.
+func (u *updater) setFilter(table, expr string) error {
+	if expr == "" {
+		return nil
+	}
+
+	f, err := mquery.ParseFilter(expr)
+	if err != nil {
+		return fmt.Errorf("failed to parse filter of table %s: %w", table, err)
+	}
+
+	cols, err := u.tableColumns(table)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool, len(cols.names))
+	for _, n := range cols.names {
+		names[strings.ToLower(n)] = true
+	}
+	for _, n := range f.Columns() {
+		if !names[n] {
+			return fmt.Errorf("invalid filter of table %s: unknown column %s", table, n)
+		}
+	}
+
+	u.mu.Lock()
+	u.filters[table] = f
+	u.mu.Unlock()
+
+	return nil
+}
+
+func (u *updater) filter(table string) *mquery.Filter {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	return u.filters[table]
+}
+
+// covers reports whether the filters of the tables a select query reads do not change its results.
+func (u *updater) covers(q *mquery.QueryStmt) bool {
+	for _, t := range q.GetRequiredTables() {
+		if f := u.filter(t); f != nil && !f.Covers(q) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterEvent applies the filter of a table to the change event of an insert, update or upsert.
+// It returns a delete event if the new row does not match the filter, or an insert event if the
+// new row of an update matches it but the old row does not.
+func (u *updater) filterEvent(e *ChangeEvent) (*ChangeEvent, error) {
+	f := u.filter(e.Table)
+	if f == nil {
+		return e, nil
+	}
+
+	match, err := f.Match(lowerKeys(e.New))
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter row: %w", err)
+	}
+
+	switch {
+	case !match:
+		old := e.Old
+		if old == nil {
+			old = e.New
+		}
+		return &ChangeEvent{Table: e.Table, Sequence: e.Sequence, Op: OpDelete, Old: old}, nil
+
+	case e.Op == OpUpdate:
+		if match, err := f.Match(lowerKeys(e.Old)); err == nil && !match {
+			return &ChangeEvent{Table: e.Table, Sequence: e.Sequence, Op: OpInsert, New: e.New}, nil
+		}
+	}
+
+	return e, nil
+}
+
+func lowerKeys(row map[string]interface{}) map[string]interface{} {
+	r := make(map[string]interface{}, len(row))
+	for k, v := range row {
+		r[strings.ToLower(k)] = v
+	}
+	return r
+}
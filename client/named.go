@@ -0,0 +1,48 @@
+package client //nolint // Package comment located in a different file.
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+)
+
+// Named statements.
+
+// ExecuteNamed executes a named statement of a table, declared in its data origin config. The args
+// are for the placeholder parameters of the statement, and are checked against its declared
+// parameters before it is sent.
+func (c *Client) ExecuteNamed(ctx context.Context, table, name string, args ...interface{}) (sql.Result, error) {
+	do, err := c.reg.GetDataOrigin(table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data origin for table: %w", err)
+	}
+
+	s, err := do.Statement(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid statement: %w", &QueryError{
+			Code: CodeInvalidRequest, Statement: -1, Msg: err.Error(),
+		})
+	}
+
+	vs := pb.MarshalValues(args)
+	if err := s.CheckArgs(pb.UnmarshalValues(vs)); err != nil {
+		return nil, fmt.Errorf("invalid args of statement %s: %w", name, &QueryError{
+			Code: CodeInvalidRequest, Statement: -1, Msg: err.Error(),
+		})
+	}
+
+	req := &pb.QueryRequest{
+		Table:     table,
+		Statement: name,
+		Args:      vs,
+	}
+
+	res, err := requestWrite(ctx, c.nats.natsConn(), do.WriteTopic(), req, c.writeRetry, c.cred)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.GetResult(), nil
+}
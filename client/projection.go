@@ -0,0 +1,142 @@
+package client //nolint // Package comment located in a different file.
+
+import (
+	"fmt"
+	"strings"
+
+	mquery "github.com/hojulian/microdb/query"
+)
+
+// Column projection.
+
+// setColumns narrows a local table to the given columns, which must include its primary key. The
+// table is created again with the type, NOT NULL and DEFAULT clauses of the columns only.
+func (u *updater) setColumns(table string, columns []string) error {
+	if len(columns) == 0 {
+		return nil
+	}
+
+	info, err := tableInfo(u.db, table)
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]int, len(info))
+	for i, c := range info {
+		index[strings.ToLower(c.name)] = i
+	}
+
+	selected := make(map[int]bool, len(columns))
+	for _, n := range columns {
+		i, ok := index[strings.ToLower(n)]
+		if !ok {
+			return fmt.Errorf("invalid columns of table %s: unknown column %s", table, n)
+		}
+		selected[i] = true
+	}
+
+	cols := &tableColumns{width: len(info)}
+	defs := make([]string, 0, len(columns)+1)
+	pks := make(map[int]string)
+	for i, c := range info {
+		if c.pk > 0 {
+			if !selected[i] {
+				return fmt.Errorf("invalid columns of table %s: missing primary key column %s", table, c.name)
+			}
+			pks[c.pk] = quoteIdent(c.name)
+		}
+		if !selected[i] {
+			continue
+		}
+
+		def := quoteIdent(c.name) + " " + c.typ
+		if c.notNull {
+			def += " NOT NULL"
+		}
+		if c.dflt.Valid {
+			def += " DEFAULT " + c.dflt.String
+		}
+		defs = append(defs, def)
+
+		cols.names = append(cols.names, c.name)
+		cols.source = append(cols.source, i)
+	}
+	if len(pks) == 0 {
+		return fmt.Errorf("invalid columns of table %s: table has no primary key", table)
+	}
+
+	keys := make([]string, 0, len(pks))
+	for i := 1; i <= len(pks); i++ {
+		keys = append(keys, pks[i])
+		for j, s := range cols.source {
+			if info[s].pk == i {
+				cols.keys = append(cols.keys, j)
+			}
+		}
+	}
+	defs = append(defs, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(keys, ", ")))
+
+	names := make([]string, len(cols.names))
+	for i, n := range cols.names {
+		names[i] = quoteIdent(n)
+	}
+	cols.insert = fmt.Sprintf("REPLACE INTO %s (%s) VALUES (%s)", quoteIdent(table),
+		strings.Join(names, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", "))
+
+	if _, err := u.db.Exec(fmt.Sprintf("DROP TABLE %s", quoteIdent(table))); err != nil {
+		return fmt.Errorf("failed to drop table %s: %w", table, err)
+	}
+	q := fmt.Sprintf("CREATE TABLE %s (%s)", quoteIdent(table), strings.Join(defs, ", "))
+	if _, err := u.db.Exec(q); err != nil {
+		return fmt.Errorf("failed to create projected table %s: %w", table, err)
+	}
+
+	u.mu.Lock()
+	u.columns[table] = cols
+	u.mu.Unlock()
+
+	return nil
+}
+
+// project returns the values of the columns of a local table from the values of a row update.
+func (c *tableColumns) project(vs []interface{}) ([]interface{}, error) {
+	if c.source == nil || len(vs) == 0 {
+		return vs, nil
+	}
+	if len(vs) != c.width {
+		return nil, fmt.Errorf("failed to project row: got %d values, expected %d", len(vs), c.width)
+	}
+
+	r := make([]interface{}, len(c.source))
+	for i, s := range c.source {
+		r[i] = vs[s]
+	}
+	return r, nil
+}
+
+// hasColumns reports whether the local tables a select query reads have every column it refers to.
+func (u *updater) hasColumns(q *mquery.QueryStmt) bool {
+	projected := false
+	local := make(map[string]bool)
+	for _, t := range q.GetRequiredTables() {
+		cols, err := u.tableColumns(t)
+		if err != nil {
+			return false
+		}
+
+		projected = projected || cols.source != nil
+		for _, n := range cols.names {
+			local[strings.ToLower(n)] = true
+		}
+	}
+	if !projected {
+		return true
+	}
+
+	for _, n := range q.GetRequiredColumns() {
+		if !local[n] {
+			return false
+		}
+	}
+	return true
+}
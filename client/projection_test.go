@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nats-io/stan.go"
+	stanpb "github.com/nats-io/stan.go/pb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+	mquery "github.com/hojulian/microdb/query"
+)
+
+func TestSetColumns(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		columns []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			desc: "all columns",
+			want: []string{"id", "name", "age"},
+		},
+		{
+			desc:    "projected",
+			columns: []string{"ID", "age"},
+			want:    []string{"id", "age"},
+		},
+		{
+			desc:    "unknown column",
+			columns: []string{"id", "email"},
+			want:    []string{"id", "name", "age"},
+			wantErr: true,
+		},
+		{
+			desc:    "missing primary key",
+			columns: []string{"name"},
+			want:    []string{"id", "name", "age"},
+			wantErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			u, _ := testUpdater(t)
+
+			err := u.setColumns("test", tC.columns)
+			if tC.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			info, err := tableInfo(u.db, "test")
+			if !assert.NoError(t, err) {
+				return
+			}
+			var got []string
+			for _, c := range info {
+				got = append(got, c.name)
+			}
+			assert.Equal(t, tC.want, got)
+		})
+	}
+}
+
+func TestProjectedTable(t *testing.T) {
+	u, origin := testUpdater(t)
+	if !assert.NoError(t, u.setColumns("test", []string{"id", "name"})) {
+		return
+	}
+	c := &Client{reg: u.reg, mdb: u.db, tables: map[string]stan.Subscription{"test": nil}, updater: u}
+
+	// Row updates hold every column of the data origin table, only the projected ones are stored.
+	p, err := proto.Marshal(&pb.RowUpdate{
+		Op:  pb.RowOp_ROW_OP_INSERT,
+		Row: pb.MarshalValues([]interface{}{int64(1), "local", int64(10)}),
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	u.handler("test")(&stan.Msg{MsgProto: stanpb.MsgProto{Sequence: 1, Data: p}})
+	assert.Equal(t, [][]interface{}{{int64(1), "local"}}, rows(t, u.db, "test", 2))
+
+	// The data origin holds other values, to tell where queries are executed.
+	insert(t, origin, "test", 3, [][]interface{}{{int64(1), "origin", int64(20)}})
+	u.mu.Lock()
+	u.progress["test"].known = true
+	u.mu.Unlock()
+
+	testCases := []struct {
+		desc  string
+		q     string
+		local bool
+		want  []interface{}
+	}{
+		{
+			desc:  "projected columns",
+			q:     "SELECT id, name FROM test WHERE id = 1",
+			local: true,
+			want:  []interface{}{int64(1), "local"},
+		},
+		{
+			desc: "other column",
+			q:    "SELECT id, age FROM test",
+			want: []interface{}{int64(1), int64(20)},
+		},
+		{
+			desc: "other column in condition",
+			q:    "SELECT name FROM test WHERE age > 1",
+			want: []interface{}{"origin"},
+		},
+		{
+			desc: "all columns",
+			q:    "SELECT * FROM test",
+			want: []interface{}{int64(1), "origin", int64(20)},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			q, err := mquery.Query(tC.q)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tC.local, u.hasColumns(q))
+
+			rs, err := c.Query(context.Background(), tC.q)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer rs.Close()
+
+			got := make([]interface{}, len(tC.want))
+			ptrs := make([]interface{}, len(got))
+			for i := range got {
+				ptrs[i] = &got[i]
+			}
+			if assert.True(t, rs.Next()) && assert.NoError(t, rs.Scan(ptrs...)) {
+				assert.Equal(t, tC.want, got)
+			}
+		})
+	}
+}
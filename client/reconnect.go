@@ -0,0 +1,317 @@
+package client //nolint // Package comment located in a different file.
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/stan.go"
+
+	"github.com/hojulian/microdb/internal/logger"
+	"github.com/hojulian/microdb/microdb"
+)
+
+// Reconnecting to NATS.
+
+const (
+	// DefaultPingInterval is the interval in seconds at which clients ping NATS Streaming, and
+	// DefaultPingMaxOut the number of pings without a reply after which the connection is lost.
+	DefaultPingInterval = 5
+	DefaultPingMaxOut   = 6
+
+	// DefaultReconnectWait is how long a client waits between attempts to reconnect to NATS.
+	DefaultReconnectWait = 5 * time.Second
+)
+
+// natsSession is a NATS Streaming connection that is replaced once it is lost.
+type natsSession struct {
+	host, port, clusterID, clientID string
+
+	mu sync.RWMutex
+	sc stan.Conn
+	nc *nats.Conn
+	// lost is the reason the connection was lost, until it is replaced.
+	lost   error
+	closed chan struct{}
+
+	// onLost is called once the connection is lost, and onReconnect with the new connection before
+	// it is installed. The new connection is closed and replaced again if onReconnect fails.
+	onLost      func(error)
+	onReconnect func(stan.Conn) error
+}
+
+func dialSession(host, port, clusterID, clientID string) (*natsSession, error) {
+	s := &natsSession{
+		host:      host,
+		port:      port,
+		clusterID: clusterID,
+		clientID:  clientID,
+		closed:    make(chan struct{}),
+	}
+
+	sc, err := s.dial()
+	if err != nil {
+		return nil, err
+	}
+	s.sc, s.nc = sc, sc.NatsConn()
+
+	return s, nil
+}
+
+func (s *natsSession) dial() (stan.Conn, error) {
+	sc, err := microdb.NATSConn(s.host, s.port, s.clusterID, s.clientID,
+		[]stan.Option{
+			stan.Pings(DefaultPingInterval, DefaultPingMaxOut),
+			stan.SetConnectionLostHandler(s.connectionLost),
+		},
+		[]nats.Option{nats.MaxReconnects(-1)},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	return sc, nil
+}
+
+// conn returns the current connection. It fails to publish and subscribe while it is lost.
+func (s *natsSession) conn() stan.Conn {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sc
+}
+
+// natsConn returns the NATS connection of the current connection. Unlike NATS Streaming, NATS
+// reconnects by itself, so writes could be sent while the connection to NATS Streaming is lost.
+func (s *natsSession) natsConn() *nats.Conn {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.nc
+}
+
+// err returns why the connection was lost, or nil if it is connected.
+func (s *natsSession) err() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.lost
+}
+
+func (s *natsSession) connectionLost(_ stan.Conn, reason error) {
+	if reason == nil {
+		reason = errors.New("connection lost")
+	}
+
+	s.mu.Lock()
+	if s.isClosed() || s.lost != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.lost = reason
+	onLost := s.onLost
+	s.mu.Unlock()
+
+	logger.Logger("client").Printf("lost connection to NATS: %s", reason)
+	if onLost != nil {
+		onLost(reason)
+	}
+
+	go s.reconnect()
+}
+
+// reconnect replaces the lost connection until it succeeds or the session is closed.
+func (s *natsSession) reconnect() {
+	log := logger.Logger("client")
+
+	for {
+		sc, err := s.dial()
+		if err == nil {
+			if err = s.replace(sc); err == nil {
+				log.Print("reconnected to NATS")
+				return
+			}
+		}
+		log.Printf("failed to reconnect to NATS: %s", err)
+
+		select {
+		case <-s.closed:
+			return
+		case <-time.After(DefaultReconnectWait):
+		}
+	}
+}
+
+func (s *natsSession) replace(sc stan.Conn) error {
+	nc := sc.NatsConn()
+
+	s.mu.RLock()
+	closed := s.isClosed()
+	onReconnect := s.onReconnect
+	s.mu.RUnlock()
+	if closed {
+		closeConn(sc, nc)
+		return nil
+	}
+
+	// The new connection is only installed once the tables are subscribed again with it.
+	if onReconnect != nil {
+		if err := onReconnect(sc); err != nil {
+			closeConn(sc, nc)
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	if s.isClosed() {
+		s.mu.Unlock()
+		closeConn(sc, nc)
+		return nil
+	}
+	oldNC := s.nc
+	s.sc, s.nc = sc, nc
+	s.lost = nil
+	s.mu.Unlock()
+
+	if oldNC != nil {
+		oldNC.Close()
+	}
+
+	return nil
+}
+
+// close closes the connection, and stops reconnecting.
+func (s *natsSession) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isClosed() {
+		return nil
+	}
+	close(s.closed)
+
+	err := s.sc.Close()
+	if s.nc != nil {
+		s.nc.Close()
+	}
+	if err != nil && s.lost == nil {
+		return fmt.Errorf("failed to close nats connection: %w", err)
+	}
+
+	return nil
+}
+
+func (s *natsSession) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func closeConn(sc stan.Conn, nc *nats.Conn) {
+	sc.Close() //nolint // The connection is discarded.
+	if nc != nil {
+		nc.Close()
+	}
+}
+
+// resubscribe subscribes to the tables of the client again with a new connection, from the row
+// update following the last one handled. The subscriptions of the client are only replaced once
+// every table is subscribed again.
+func (c *Client) resubscribe(sc stan.Conn) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	subs, err := c.updater.resubscribeAll(c.reg, c.tables, sc)
+	if err != nil {
+		return err
+	}
+	for t, sub := range subs {
+		c.tables[t] = sub
+	}
+
+	return nil
+}
+
+// resubscribeAll subscribes to tables with a new connection, and returns the new subscriptions once
+// all of them succeeded. The tables are then probed again, and catch up from the row update
+// following the last one handled. Otherwise, the new subscriptions are closed.
+func (u *updater) resubscribeAll(reg *microdb.Registry, tables map[string]stan.Subscription,
+	sc stan.Conn) (map[string]stan.Subscription, error) {
+	subs := make(map[string]stan.Subscription, len(tables))
+	replays := make(map[string]*nats.Subscription, len(tables))
+	for t := range tables {
+		sub, replay, err := u.resubscribe(reg, t, sc)
+		if err != nil {
+			for _, s := range subs {
+				s.Unsubscribe() //nolint // The new connection is closed anyway.
+			}
+			for _, s := range replays {
+				s.Unsubscribe() //nolint // The new connection is closed anyway.
+			}
+			return nil, err
+		}
+		subs[t], replays[t] = sub, replay
+	}
+
+	for t := range subs {
+		u.setReplay(t, replays[t])
+
+		do, err := reg.GetDataOrigin(t)
+		if err != nil {
+			continue
+		}
+
+		u.mu.Lock()
+		if p, ok := u.progress[t]; ok {
+			p.stale, p.known = false, false
+		}
+		u.mu.Unlock()
+		go u.probe(t, do.ReadTopic())
+	}
+
+	return subs, nil
+}
+
+// resubscribe subscribes to a table and its replayed dead letters with a new connection, from the
+// row update following the last one handled.
+func (u *updater) resubscribe(reg *microdb.Registry, table string,
+	sc stan.Conn) (stan.Subscription, *nats.Subscription, error) {
+	u.mu.RLock()
+	start := stan.DeliverAllAvailable()
+	if p, ok := u.progress[table]; ok && p.handled > 0 {
+		start = stan.StartAtSequence(p.handled + 1)
+	}
+	u.mu.RUnlock()
+
+	sub, err := subscribeTable(reg, table, sc, u.handler(table), start)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to subscribe to table again: %w", err)
+	}
+	replay, err := u.replaySubscription(table, sc.NatsConn())
+	if err != nil {
+		sub.Unsubscribe() //nolint // Best effort clean up.
+		return nil, nil, err
+	}
+
+	return sub, replay, nil
+}
+
+// stale marks all tables stale, once the connection is lost.
+func (u *updater) stale(error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, p := range u.progress {
+		p.stale = true
+		select {
+		case <-p.ready:
+			p.ready = make(chan struct{})
+		default:
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	stand "github.com/nats-io/nats-streaming-server/server"
+	"github.com/nats-io/stan.go"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+	"github.com/hojulian/microdb/microdb"
+)
+
+const testClusterID = "test-cluster"
+
+// runStreamingServer runs an embedded NATS Streaming server, and returns its host and port.
+func runStreamingServer(t *testing.T) (string, string) {
+	t.Helper()
+
+	opts := stand.GetDefaultOptions()
+	opts.ID = testClusterID
+
+	nopts := stand.DefaultNatsServerOptions
+	nopts.Host = "127.0.0.1"
+	nopts.Port = freePort(t)
+	// Without headers, the server detects other instances by a request timing out rather than
+	// failing without responders.
+	nopts.NoHeaderSupport = true
+
+	s, err := stand.RunServerWithOpts(opts, &nopts)
+	if err != nil {
+		t.Fatalf("failed to run NATS Streaming server: %s", err)
+	}
+	t.Cleanup(s.Shutdown)
+
+	return nopts.Host, strconv.Itoa(nopts.Port)
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %s", err)
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// reconnectClient returns a client subscribed to the table test, and a function publishing row
+// updates inserting rows of the given ids into the table.
+func reconnectClient(t *testing.T, clientID string) (*Client, func(ids ...int64)) {
+	t.Helper()
+
+	host, port := runStreamingServer(t)
+	reg, _ := testRegistry(t)
+
+	c, err := connect(reg, host, port, clientID, testClusterID)
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	sc, err := microdb.NATSConn(host, port, testClusterID, clientID+"-publisher", nil, nil)
+	if err != nil {
+		t.Fatalf("failed to connect publisher: %s", err)
+	}
+	t.Cleanup(func() { sc.Close() })
+
+	publish := func(ids ...int64) {
+		for _, id := range ids {
+			p, err := proto.Marshal(&pb.RowUpdate{
+				Op:  pb.RowOp_ROW_OP_INSERT,
+				Row: pb.MarshalValues([]interface{}{id, "test", int64(1)}),
+			})
+			if err != nil {
+				t.Fatalf("failed to marshal row update: %s", err)
+			}
+			if err := sc.Publish("test_table", p); err != nil {
+				t.Fatalf("failed to publish row update: %s", err)
+			}
+		}
+	}
+
+	return c, publish
+}
+
+// waitLive waits until the table test has handled n row updates and is live.
+func waitLive(t *testing.T, c *Client, n uint64) {
+	t.Helper()
+
+	assert.Eventually(t, func() bool {
+		ss, err := c.Stats()
+		return err == nil && len(ss) == 1 && ss[0].State == SyncLive && ss[0].AppliedSequence == n
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+func TestReconnect(t *testing.T) {
+	c, publish := reconnectClient(t, "client-reconnect-test")
+	publish(1, 2)
+	if err := c.subscribe([]Table{{Name: "test"}}); err != nil {
+		t.Fatalf("failed to subscribe: %s", err)
+	}
+	waitLive(t, c, 2)
+	ready, err := c.Ready("test")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Tables are stale until they are subscribed again.
+	var states []SyncState
+	reconnected := make(chan struct{})
+	c.nats.onReconnect = func(sc stan.Conn) error {
+		h, _ := c.TableHealth("test")
+		states = append(states, h.State)
+
+		err := c.resubscribe(sc)
+		close(reconnected)
+		return err
+	}
+
+	lost := c.nats.conn()
+	lost.Close() //nolint // The connection is replaced.
+	publish(3)
+	c.nats.connectionLost(lost, errors.New("connection lost"))
+
+	select {
+	case <-reconnected:
+	case <-time.After(10 * time.Second):
+		t.Fatal("client did not reconnect")
+	}
+	assert.Equal(t, []SyncState{SyncStale}, states)
+	assert.NotEqual(t, lost, c.nats.conn())
+	assert.NoError(t, c.nats.err())
+
+	// The table is probed again, and catches up from the row update following the last one
+	// handled, without applying the first ones again.
+	waitLive(t, c, 3)
+	ss, err := c.Stats()
+	if assert.NoError(t, err) && assert.Len(t, ss, 1) {
+		assert.Equal(t, uint64(3), ss[0].Applied)
+		assert.Equal(t, int64(3), ss[0].Rows)
+		assert.True(t, ss[0].Subscribed)
+	}
+
+	// Tables were live before the connection was lost, so the former ready channel stays closed.
+	select {
+	case <-ready:
+	default:
+		t.Error("ready channel is not closed")
+	}
+	ready, err = c.Ready("test")
+	if assert.NoError(t, err) {
+		select {
+		case <-ready:
+		default:
+			t.Error("ready channel is not closed once live again")
+		}
+	}
+}
+
+func TestReconnectFailed(t *testing.T) {
+	c, publish := reconnectClient(t, "client-reconnect-failed-test")
+	publish(1)
+	if err := c.subscribe([]Table{{Name: "test"}}); err != nil {
+		t.Fatalf("failed to subscribe: %s", err)
+	}
+	waitLive(t, c, 1)
+
+	c.nats.mu.Lock()
+	c.nats.lost = errors.New("connection lost")
+	c.nats.mu.Unlock()
+	c.updater.stale(c.nats.err())
+
+	old := c.nats.conn()
+	c.mu.RLock()
+	oldSub := c.tables["test"]
+	c.mu.RUnlock()
+
+	// Subscribing with a closed connection fails, so the connection is not replaced.
+	sc, err := microdb.NATSConn(c.nats.host, c.nats.port, testClusterID, "client-reconnect-failed-new", nil, nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	sc.Close() //nolint // The connection fails to subscribe once closed.
+
+	assert.Error(t, c.nats.replace(sc))
+	assert.Equal(t, old, c.nats.conn())
+	assert.Error(t, c.nats.err())
+
+	c.mu.RLock()
+	assert.Equal(t, oldSub, c.tables["test"])
+	c.mu.RUnlock()
+
+	h, err := c.TableHealth("test")
+	if assert.NoError(t, err) {
+		assert.Equal(t, SyncStale, h.State)
+	}
+}
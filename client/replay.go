@@ -0,0 +1,238 @@
+package client //nolint // Package comment located in a different file.
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+)
+
+// Dead letter replay.
+
+// DefaultReplayTimeout is how long a client reads the rows of a replayed dead letter from the data
+// origin at most.
+const DefaultReplayTimeout = 10 * time.Second
+
+// subscribeReplay subscribes to the dead letters of a table replayed to the client, on the topic
+// of its NATS client ID. The subscription ends with the NATS connection.
+func (u *updater) subscribeReplay(table string, nc *nats.Conn) error {
+	sub, err := u.replaySubscription(table, nc)
+	if err != nil {
+		return err
+	}
+	u.setReplay(table, sub)
+
+	return nil
+}
+
+func (u *updater) replaySubscription(table string, nc *nats.Conn) (*nats.Subscription, error) {
+	do, err := u.reg.GetDataOrigin(table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data origin for table: %w", err)
+	}
+
+	sub, err := nc.Subscribe(do.ReplayTopic(u.nats.clientID), u.replayHandler(table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to replayed dead letters: %w", err)
+	}
+
+	return sub, nil
+}
+
+// setReplay replaces the replay subscription of a table.
+func (u *updater) setReplay(table string, sub *nats.Subscription) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if old, ok := u.replays[table]; ok {
+		old.Unsubscribe() //nolint // The subscription of a lost connection is gone already.
+	}
+	u.replays[table] = sub
+}
+
+// replayHandler replies to a replayed dead letter with an empty message once it is applied, or
+// with the error otherwise.
+func (u *updater) replayHandler(table string) nats.MsgHandler {
+	return func(m *nats.Msg) {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultReplayTimeout)
+		defer cancel()
+
+		var reply []byte
+		if err := u.replay(ctx, table, m.Data); err != nil {
+			reply = []byte(err.Error())
+		}
+		m.Respond(reply) //nolint // The replay command reports missing replies.
+	}
+}
+
+// replay reads the rows of a dead letter from the data origin again, and replaces them in the local
+// table. Rows are read again rather than taken from the dead letter, which would overwrite the
+// changes applied since the row update failed.
+func (u *updater) replay(ctx context.Context, table string, data []byte) error {
+	var dl pb.DeadLetter
+	if err := proto.Unmarshal(data, &dl); err != nil {
+		return fmt.Errorf("failed to parse dead letter: %w", err)
+	}
+	if dl.GetTable() != table {
+		return fmt.Errorf("failed to replay dead letter: got table %s, expected %s", dl.GetTable(), table)
+	}
+
+	var ru pb.RowUpdate
+	if err := proto.Unmarshal(dl.GetData(), &ru); err != nil {
+		return fmt.Errorf("failed to parse row update: %w", err)
+	}
+
+	cols, err := u.tableColumns(table)
+	if err != nil {
+		return err
+	}
+	if len(cols.keys) == 0 {
+		return fmt.Errorf("failed to replay dead letter: table %s has no primary key", table)
+	}
+
+	row, err := cols.project(pb.UnmarshalValues(ru.GetRow()))
+	if err != nil {
+		return fmt.Errorf("%w, got: %s", err, ru.String())
+	}
+	old, err := cols.project(pb.UnmarshalValues(ru.GetOldRow()))
+	if err != nil {
+		return fmt.Errorf("%w, got: %s", err, ru.String())
+	}
+
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create update transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint // Rolling back a committed transaction is a no-op.
+
+	// The primary key could have been updated, so the old row is read again too.
+	var es []*ChangeEvent
+	for _, r := range [][]interface{}{old, row} {
+		if len(r) == 0 {
+			continue
+		}
+
+		e, err := u.refreshRow(ctx, tx, table, cols, r)
+		if err != nil {
+			return err
+		}
+		if e != nil {
+			e.Sequence = dl.GetSequence()
+			es = append(es, e)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed commit update to table: %w", err)
+	}
+
+	u.resultCache().invalidate(table)
+	for _, e := range es {
+		u.notify(e)
+	}
+
+	return nil
+}
+
+// refreshRow replaces a row of a local table with the row of the same primary key of its data
+// origin, or deletes it if the data origin no longer has it or it does not match the filter of the
+// table. It returns the change event of the row, if any.
+func (u *updater) refreshRow(ctx context.Context, tx *sql.Tx, table string, cols *tableColumns,
+	row []interface{}) (*ChangeEvent, error) {
+	vs, err := u.originRow(ctx, table, cols, row)
+	if err != nil {
+		return nil, err
+	}
+
+	var e *ChangeEvent
+	if vs != nil {
+		if e, err = u.filterEvent(&ChangeEvent{Table: table, Op: OpUpsert, New: cols.row(vs)}); err != nil {
+			return nil, err
+		}
+	}
+
+	n, err := deleteRow(tx, table, cols, row)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil || e.Op == OpDelete {
+		if n == 0 {
+			return nil, nil
+		}
+		return &ChangeEvent{Table: table, Op: OpDelete, Old: cols.row(row)}, nil
+	}
+
+	if err := u.insertRow(tx, table, cols, vs); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// originRow reads the columns of a local table from its data origin, for the row with the primary
+// key of the given row. It returns nil if the data origin does not have the row.
+func (u *updater) originRow(ctx context.Context, table string, cols *tableColumns,
+	row []interface{}) ([]interface{}, error) {
+	do, err := u.reg.GetDataOrigin(table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data origin for table: %w", err)
+	}
+	db, err := do.GetDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connector for data origin: %w", err)
+	}
+
+	conds := make([]string, 0, len(cols.keys))
+	args := make([]interface{}, 0, len(cols.keys))
+	for _, k := range cols.keys {
+		conds = append(conds, cols.names[k]+" = ?")
+		args = append(args, row[k])
+	}
+	q := fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(cols.names, ", "), table,
+		strings.Join(conds, " AND "))
+
+	rs, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read row of table %s from data origin: %w", table, err)
+	}
+	defer rs.Close()
+
+	types, err := rs.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of table %s: %w", table, err)
+	}
+
+	if !rs.Next() {
+		if err := rs.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read row of table %s from data origin: %w", table, err)
+		}
+		return nil, nil
+	}
+
+	vs := make([]interface{}, len(types))
+	ptrs := make([]interface{}, len(types))
+	for i := range vs {
+		ptrs[i] = &vs[i]
+	}
+	if err := rs.Scan(ptrs...); err != nil {
+		return nil, fmt.Errorf("failed to read row of table %s from data origin: %w", table, err)
+	}
+	for i, v := range vs {
+		// MySQL returns text as bytes, which SQLite would not compare equal to strings.
+		if b, ok := v.([]byte); ok && !binary(types[i].DatabaseTypeName()) {
+			vs[i] = string(b)
+		}
+	}
+
+	if rs.Next() {
+		return nil, errors.New("failed to read row from data origin: primary key matches several rows")
+	}
+
+	return vs, nil
+}
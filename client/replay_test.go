@@ -0,0 +1,202 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+	"github.com/hojulian/microdb/microdb"
+)
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []string
+		local   [][]interface{}
+		origin  [][]interface{}
+		table   string
+		update  *pb.RowUpdate
+		want    [][]interface{}
+		wantErr bool
+	}{
+		{
+			name:   "stale row update",
+			local:  [][]interface{}{{int64(1), "a", int64(1)}, {int64(2), "b", int64(2)}},
+			origin: [][]interface{}{{int64(1), "c", int64(3)}, {int64(2), "d", int64(4)}},
+			update: &pb.RowUpdate{Row: pb.MarshalValues([]interface{}{int64(1), "b", int64(2)})},
+			// Only the row of the dead letter is read again, as it is on the data origin now.
+			want: [][]interface{}{{int64(1), "c", int64(3)}, {int64(2), "b", int64(2)}},
+		},
+		{
+			name:   "deleted row",
+			local:  [][]interface{}{{int64(1), "a", int64(1)}, {int64(2), "b", int64(2)}},
+			origin: [][]interface{}{{int64(2), "b", int64(2)}},
+			update: &pb.RowUpdate{Row: pb.MarshalValues([]interface{}{int64(1), "a", int64(5)})},
+			want:   [][]interface{}{{int64(2), "b", int64(2)}},
+		},
+		{
+			name:   "updated primary key",
+			local:  [][]interface{}{{int64(1), "a", int64(1)}},
+			origin: [][]interface{}{{int64(3), "a", int64(1)}},
+			update: &pb.RowUpdate{
+				Op:     pb.RowOp_ROW_OP_UPDATE,
+				Row:    pb.MarshalValues([]interface{}{int64(3), "a", int64(1)}),
+				OldRow: pb.MarshalValues([]interface{}{int64(1), "a", int64(1)}),
+			},
+			want: [][]interface{}{{int64(3), "a", int64(1)}},
+		},
+		{
+			name:    "projected table",
+			columns: []string{"id", "name"},
+			local:   [][]interface{}{{int64(1), "a"}},
+			origin:  [][]interface{}{{int64(1), "c", int64(3)}},
+			update:  &pb.RowUpdate{Row: pb.MarshalValues([]interface{}{int64(1), "b", int64(2)})},
+			want:    [][]interface{}{{int64(1), "c"}},
+		},
+		{
+			name:    "other table",
+			local:   [][]interface{}{{int64(1), "a", int64(1)}},
+			origin:  [][]interface{}{{int64(1), "c", int64(3)}},
+			table:   "other",
+			update:  &pb.RowUpdate{Row: pb.MarshalValues([]interface{}{int64(1), "b", int64(2)})},
+			want:    [][]interface{}{{int64(1), "a", int64(1)}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, origin := testUpdater(t)
+			if !assert.NoError(t, u.setColumns("test", tt.columns)) {
+				return
+			}
+			cols, err := u.tableColumns("test")
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			insert(t, u.db, "test", len(cols.names), tt.local)
+			insert(t, origin, "test", 3, tt.origin)
+
+			table := tt.table
+			if table == "" {
+				table = "test"
+			}
+			ru, err := proto.Marshal(tt.update)
+			if !assert.NoError(t, err) {
+				return
+			}
+			dl, err := proto.Marshal(&pb.DeadLetter{Table: table, Data: ru, Sequence: 1, ClientId: "client-1"})
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			err = u.replay(context.Background(), "test", dl)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, rows(t, u.db, "test", len(cols.names)))
+		})
+	}
+}
+
+// testUpdater returns an updater of a local table test, and the database of its data origin.
+func testUpdater(t *testing.T) (*updater, *sql.DB) {
+	t.Helper()
+
+	reg, origin := testRegistry(t)
+
+	db, err := sql.Open("sqlite3", localDSN())
+	if err != nil {
+		t.Fatalf("failed to open local database: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := createTable(reg, db, "test"); err != nil {
+		t.Fatalf("failed to create local table: %s", err)
+	}
+
+	return newUpdater(reg, db, &natsSession{clientID: "client-1"}), origin
+}
+
+// testRegistry returns a registry of a table test on a SQLite data origin, and its database.
+func testRegistry(t *testing.T) (*microdb.Registry, *sql.DB) {
+	t.Helper()
+
+	schema := "CREATE TABLE test (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)"
+	dsn := filepath.Join(t.TempDir(), "origin.db")
+
+	reg := microdb.NewRegistry()
+	err := reg.AddDataOrigin("test", func() (*microdb.DataOrigin, error) {
+		return &microdb.DataOrigin{
+			Schema: &microdb.Schema{
+				Table:            "test",
+				OriginTableQuery: schema,
+				LocalTableQuery:  schema,
+				InsertQuery:      "REPLACE INTO test VALUES (?, ?, ?)",
+			},
+			Connection: &microdb.ConnectionCfg{OriginType: microdb.DataOriginTypeSQLite3, Dsn: dsn},
+		}, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to add data origin: %s", err)
+	}
+
+	do, err := reg.GetDataOrigin("test")
+	if err != nil {
+		t.Fatalf("failed to get data origin: %s", err)
+	}
+	origin, err := do.GetDB()
+	if err != nil {
+		t.Fatalf("failed to open data origin: %s", err)
+	}
+	t.Cleanup(func() { origin.Close() })
+	if _, err := origin.Exec(schema); err != nil {
+		t.Fatalf("failed to create origin table: %s", err)
+	}
+
+	return reg, origin
+}
+
+func insert(t *testing.T, db *sql.DB, table string, n int, rs [][]interface{}) {
+	t.Helper()
+
+	q := fmt.Sprintf("INSERT INTO %s VALUES (?%s)", table, strings.Repeat(", ?", n-1))
+	for _, r := range rs {
+		if _, err := db.Exec(q, r...); err != nil {
+			t.Fatalf("failed to insert row: %s", err)
+		}
+	}
+}
+
+func rows(t *testing.T, db *sql.DB, table string, n int) [][]interface{} {
+	t.Helper()
+
+	rs, err := db.Query(fmt.Sprintf("SELECT * FROM %s ORDER BY 1", table))
+	if err != nil {
+		t.Fatalf("failed to read rows: %s", err)
+	}
+	defer rs.Close()
+
+	var r [][]interface{}
+	for rs.Next() {
+		vs := make([]interface{}, n)
+		ptrs := make([]interface{}, n)
+		for i := range vs {
+			ptrs[i] = &vs[i]
+		}
+		if err := rs.Scan(ptrs...); err != nil {
+			t.Fatalf("failed to read row: %s", err)
+		}
+		r = append(r, vs)
+	}
+	return r
+}
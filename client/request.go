@@ -0,0 +1,120 @@
+package client //nolint // Package comment located in a different file.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+	"github.com/nats-io/nats.go"
+	uuid "github.com/satori/go.uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+)
+
+// Write requests to queriers.
+
+const (
+	// DefaultWriteAttemptTimeout is the default time to wait for a querier to reply to a write.
+	DefaultWriteAttemptTimeout = 5 * time.Second
+	// DefaultWriteAttempts is the default number of times a write is sent before giving up.
+	DefaultWriteAttempts = 3
+)
+
+// writeRetry represents how writes are retried.
+type writeRetry struct {
+	attemptTimeout time.Duration
+	attempts       int
+}
+
+//nolint // Used as the retry policy of new clients and connections.
+var defaultWriteRetry = writeRetry{
+	attemptTimeout: DefaultWriteAttemptTimeout,
+	attempts:       DefaultWriteAttempts,
+}
+
+// credentials identify a client to queriers.
+type credentials struct {
+	id  string
+	key []byte
+}
+
+// requestWrite sends a write request to the querier of a table and waits for its reply.
+//
+// The request is given a unique request ID, and sent again with the same ID if no querier replied
+// in time, or if the querier was overloaded. Queriers execute a request only once, so a write
+// whose reply was lost is not executed twice. The deadline of ctx is sent along, queriers abandon
+// requests past it. The request is signed with cred, if set.
+func requestWrite(ctx context.Context, nc *nats.Conn, topic string, req *pb.QueryRequest,
+	wr writeRetry, cred *credentials) (*pb.WriteQueryReply, error) {
+	if req.RequestId == "" {
+		req.RequestId = uuid.NewV4().String()
+	}
+	if d, ok := ctx.Deadline(); ok {
+		req.Deadline = timestamppb.New(d)
+	}
+	if cred != nil {
+		if err := req.Sign(cred.id, cred.key, time.Now()); err != nil {
+			return nil, fmt.Errorf("failed to sign write request: %w", err)
+		}
+	}
+
+	p, err := proto.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal write request: %w", err)
+	}
+
+	bo := backoff.NewExponentialBackOff()
+	bo.InitialInterval = 100 * time.Millisecond
+	bo.MaxElapsedTime = 0
+
+	for attempt := 1; ; attempt++ {
+		res, err := sendWrite(ctx, nc, topic, p, wr.attemptTimeout)
+		if err == nil {
+			return res, nil
+		}
+
+		// Only retry when the request was not executed, or may not have been. Timeouts of the
+		// caller's context are final.
+		retryable := errors.Is(err, nats.ErrNoResponders) || errors.Is(err, ErrOverloaded) ||
+			(errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil)
+		if !retryable || attempt >= wr.attempts {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to execute query: %w", ctx.Err())
+		case <-time.After(bo.NextBackOff()):
+		}
+	}
+}
+
+// sendWrite sends a marshaled write request once.
+func sendWrite(ctx context.Context, nc *nats.Conn, topic string, p []byte,
+	timeout time.Duration) (*pb.WriteQueryReply, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	msg, err := nc.RequestWithContext(ctx, topic, p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	var res pb.WriteQueryReply
+	if err := proto.Unmarshal(msg.Data, &res); err != nil {
+		return nil, fmt.Errorf("failed to parse reply: %w", err)
+	}
+
+	if !res.GetOk() {
+		return nil, fmt.Errorf("failed to execute query: %w", newQueryError(&res))
+	}
+
+	return &res, nil
+}
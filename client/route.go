@@ -0,0 +1,46 @@
+package client //nolint // Package comment located in a different file.
+
+import (
+	"context"
+
+	mquery "github.com/hojulian/microdb/query"
+)
+
+// Query routing.
+
+// Route represents where a query is executed.
+type Route int
+
+const (
+	// RouteAuto executes queries locally if every table they read is subscribed, healthy and live,
+	// or at the data origin otherwise.
+	RouteAuto Route = iota
+	// RouteLocal executes queries locally, even if tables are stale or not live yet. Queries of
+	// tables that are not subscribed are still executed at the data origin.
+	RouteLocal
+	// RouteOrigin executes queries at the data origin.
+	RouteOrigin
+)
+
+type routeKey struct{}
+
+// WithRoute returns a context routing the queries executed with it, unless a query has a routing
+// hint of its own, e.g. "SELECT /*+ microdb:origin */ * FROM t".
+func WithRoute(ctx context.Context, r Route) context.Context {
+	return context.WithValue(ctx, routeKey{}, r)
+}
+
+// route forces a query to the destination of the route of ctx, unless it is forced already.
+func route(ctx context.Context, q *mquery.QueryStmt) *mquery.QueryStmt {
+	if q.IsForced() {
+		return q
+	}
+
+	switch r, _ := ctx.Value(routeKey{}).(Route); r {
+	case RouteLocal:
+		return q.OnLocal()
+	case RouteOrigin:
+		return q.OnOrigin()
+	}
+	return q
+}
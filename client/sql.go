@@ -6,6 +6,7 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"sync/atomic"
 )
 
 // This file contains mostly helper structs for integrating with sql.Driver.
@@ -15,6 +16,16 @@ var (
 	_ driver.Rows      = &dRows{}
 )
 
+//nolint // Used for naming the local databases of clients and drivers.
+var localDBs uint64
+
+// localDSN returns the dsn of a new in-memory database. Each client and driver has its own, so that
+// clients of different registries could subscribe to tables of the same name.
+func localDSN() string {
+	n := atomic.AddUint64(&localDBs, 1)
+	return fmt.Sprintf("file:microdb-%d?mode=memory&cache=shared&_journal=memory&_cache_size=-64000", n)
+}
+
 type dsnConnector struct {
 	dsn    string
 	driver driver.Driver
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalDSN(t *testing.T) {
+	schema := "CREATE TABLE test (id INTEGER PRIMARY KEY, name TEXT)"
+
+	db1, err := sql.Open("sqlite3", localDSN())
+	if err != nil {
+		t.Fatalf("failed to open local database: %s", err)
+	}
+	defer db1.Close()
+
+	db2, err := sql.Open("sqlite3", localDSN())
+	if err != nil {
+		t.Fatalf("failed to open local database: %s", err)
+	}
+	defer db2.Close()
+
+	// Tables of the same name are created in each local database.
+	_, err = db1.Exec(schema)
+	assert.NoError(t, err)
+	_, err = db2.Exec(schema)
+	assert.NoError(t, err)
+
+	_, err = db1.Exec("INSERT INTO test (id, name) VALUES (1, 'test')")
+	assert.NoError(t, err)
+
+	var n int
+	assert.NoError(t, db2.QueryRow("SELECT COUNT(*) FROM test").Scan(&n))
+	assert.Equal(t, 0, n)
+
+	// Connections to the same local database share its tables.
+	ctx := context.Background()
+	c1, err := db1.Conn(ctx)
+	if err != nil {
+		t.Fatalf("failed to get connection: %s", err)
+	}
+	defer c1.Close()
+	c2, err := db1.Conn(ctx)
+	if err != nil {
+		t.Fatalf("failed to get connection: %s", err)
+	}
+	defer c2.Close()
+
+	_, err = c1.ExecContext(ctx, "INSERT INTO test (id, name) VALUES (2, 'test')")
+	assert.NoError(t, err)
+	assert.NoError(t, c2.QueryRowContext(ctx, "SELECT COUNT(*) FROM test").Scan(&n))
+	assert.Equal(t, 2, n)
+}
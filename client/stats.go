@@ -0,0 +1,125 @@
+package client //nolint // Package comment located in a different file.
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Replication statistics.
+
+// TableStats represents the replication statistics of a local table.
+type TableStats struct {
+	Table   string
+	State   SyncState
+	Healthy bool
+	// Subscribed is false once the subscription of the table is no longer active, e.g. after the
+	// connection to NATS is lost.
+	Subscribed bool
+
+	// AppliedSequence is the sequence number of the last row update handled, in the table topic.
+	AppliedSequence uint64
+	// PendingUpdates is the number of row updates published before the table was subscribed that
+	// are not handled yet.
+	PendingUpdates uint64
+	// LastCommittedAt is the time the last applied change was committed to the data origin. It is
+	// zero if the publisher did not report it, e.g. for rows of the initial dump.
+	LastCommittedAt time.Time
+	// LastAppliedAt is the time the last row update was applied.
+	LastAppliedAt time.Time
+	// Lag is the time between the commit of the last applied change to the data origin and its
+	// application to the local table, or the time since the commit if the table is not live.
+	Lag time.Duration
+
+	// Applied and Failed are the numbers of row updates applied and failed to apply.
+	Applied uint64
+	Failed  uint64
+	// Rows is the number of rows of the local table.
+	Rows int64
+}
+
+// Stats returns the replication statistics of all subscribed tables, sorted by table name.
+func (c *Client) Stats() ([]TableStats, error) {
+	c.mu.RLock()
+	subscribed := make(map[string]bool, len(c.tables))
+	for t, s := range c.tables {
+		subscribed[t] = s.IsValid()
+	}
+	c.mu.RUnlock()
+
+	ss := make([]TableStats, 0, len(subscribed))
+	for t, valid := range subscribed {
+		s, ok := c.updater.stats(t, time.Now())
+		if !ok {
+			// The table was removed meanwhile.
+			continue
+		}
+		s.Subscribed = valid
+
+		if err := c.mdb.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", quoteIdent(t))).Scan(&s.Rows); err != nil {
+			return nil, fmt.Errorf("failed to count rows of table %s: %w", t, err)
+		}
+
+		ss = append(ss, s)
+	}
+	sort.Slice(ss, func(i, j int) bool { return ss[i].Table < ss[j].Table })
+
+	return ss, nil
+}
+
+// stats returns the replication statistics of a table known to the updater.
+func (u *updater) stats(table string, now time.Time) (TableStats, bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	h, ok := u.health[table]
+	if !ok {
+		return TableStats{}, false
+	}
+	s := TableStats{
+		Table:   table,
+		Healthy: h.Healthy,
+		Failed:  h.Errors,
+	}
+
+	p, ok := u.progress[table]
+	if !ok {
+		return s, true
+	}
+	s.State = p.state()
+	s.AppliedSequence = p.handled
+	if p.known && p.handled < p.target {
+		s.PendingUpdates = p.target - p.handled
+	}
+	s.LastCommittedAt = p.committedAt
+	s.LastAppliedAt = p.appliedAt
+	s.Applied = p.applied
+
+	if !p.committedAt.IsZero() {
+		if s.State == SyncLive {
+			s.Lag = p.appliedAt.Sub(p.committedAt)
+		} else {
+			s.Lag = now.Sub(p.committedAt)
+		}
+	}
+
+	return s, true
+}
+
+// applied records a row update applied to a table, committed to the data origin at committedAt.
+func (u *updater) applied(table string, committedAt *timestamppb.Timestamp) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	p, ok := u.progress[table]
+	if !ok {
+		return
+	}
+	p.applied++
+	p.appliedAt = time.Now()
+	if committedAt != nil {
+		p.committedAt = committedAt.AsTime()
+	}
+}
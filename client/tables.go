@@ -0,0 +1,307 @@
+package client //nolint // Package comment located in a different file.
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+	"github.com/nats-io/stan.go"
+
+	"github.com/hojulian/microdb/internal/logger"
+)
+
+// Subscribing to tables at runtime.
+
+const (
+	// DefaultCatchUpProbe is how long a client waits for NATS Streaming to deliver the last row
+	// update of a table, before assuming the table has none.
+	DefaultCatchUpProbe = time.Second
+	// DefaultMaxProbeInterval is the longest a client waits between attempts to read the sequence
+	// number of the last row update of a table.
+	DefaultMaxProbeInterval = 10 * time.Second
+)
+
+// SyncState represents how far a local table is in sync with its data origin.
+type SyncState int
+
+// Sync states of local tables.
+const (
+	// SyncBootstrapping is the state of a table until the sequence number of the last row update
+	// published before it was subscribed is known.
+	SyncBootstrapping SyncState = iota
+	// SyncCatchingUp is the state of a table applying the row updates published before it was
+	// subscribed.
+	SyncCatchingUp
+	// SyncLive is the state of a table that has applied the row updates published before it was
+	// subscribed, and applies new ones as they are published.
+	SyncLive
+	// SyncStale is the state of a table while the connection to NATS is lost. Once reconnected,
+	// the table catches up from the last row update it handled.
+	SyncStale
+)
+
+// String returns the name of the state.
+func (s SyncState) String() string {
+	switch s {
+	case SyncCatchingUp:
+		return "catching up"
+	case SyncLive:
+		return "live"
+	case SyncStale:
+		return "stale"
+	default:
+		return "bootstrapping"
+	}
+}
+
+// progress represents how far the row updates of a table are handled.
+type progress struct {
+	// handled is the sequence number of the last row update handled.
+	handled uint64
+	// target is the sequence number of the last row update published before the table was
+	// subscribed, once known.
+	target uint64
+	known  bool
+	// probeErr is the error of the last attempt to read the target, until it is known.
+	probeErr error
+	// stale is set while the connection to NATS is lost.
+	stale bool
+	ready chan struct{}
+
+	// applied is the number of row updates applied, and committedAt and appliedAt the times the
+	// last one was committed to the data origin, if known, and applied.
+	applied     uint64
+	committedAt time.Time
+	appliedAt   time.Time
+}
+
+// AddTable subscribes to a table of a connected client, and creates its local table. It waits until
+// the row updates published before are handled, or ctx is done. The table stays subscribed in the
+// latter case, and keeps catching up; see Ready.
+func (c *Client) AddTable(ctx context.Context, t Table) error {
+	if err := c.addTable(t); err != nil {
+		return err
+	}
+
+	ready, err := c.Ready(t.Name)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to wait for table %s to catch up: %w", t.Name, ctx.Err())
+	}
+}
+
+// RemoveTable unsubscribes from a table, closes its watchers and drops its local table. Queries of
+// the table are then sent to the data origin.
+func (c *Client) RemoveTable(table string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sub, ok := c.tables[table]
+	if !ok {
+		return fmt.Errorf("table is not subscribed, got: %s", table)
+	}
+
+	if err := sub.Unsubscribe(); err != nil {
+		return fmt.Errorf("failed to unsubscribe table: %w", err)
+	}
+	delete(c.tables, table)
+	c.updater.remove(table)
+
+	if _, err := c.mdb.Exec(fmt.Sprintf("DROP TABLE %s", quoteIdent(table))); err != nil {
+		return fmt.Errorf("failed to drop local table: %w", err)
+	}
+
+	return nil
+}
+
+// WaitReady waits until every subscribed table is live, or ctx is done. Queries of tables that are
+// not live yet are sent to the data origin.
+func (c *Client) WaitReady(ctx context.Context) error {
+	c.mu.RLock()
+	tables := make([]string, 0, len(c.tables))
+	for t := range c.tables {
+		tables = append(tables, t)
+	}
+	c.mu.RUnlock()
+
+	for _, t := range tables {
+		ready, err := c.Ready(t)
+		if err != nil {
+			// The table was removed meanwhile.
+			continue
+		}
+
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return fmt.Errorf("failed to wait for table %s to catch up: %w", t, ctx.Err())
+		}
+	}
+
+	return nil
+}
+
+// Ready returns a channel that is closed once a subscribed table is live, i.e. it has handled the row
+// updates published before it was subscribed. It is never closed if the table is stopped; see PolicyStop.
+func (c *Client) Ready(table string) (<-chan struct{}, error) {
+	if !c.subscribed(table) {
+		return nil, fmt.Errorf("table is not subscribed, got: %s", table)
+	}
+
+	c.updater.mu.RLock()
+	defer c.updater.mu.RUnlock()
+
+	p, ok := c.updater.progress[table]
+	if !ok {
+		return nil, fmt.Errorf("table is not subscribed, got: %s", table)
+	}
+	return p.ready, nil
+}
+
+func (c *Client) subscribed(table string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, ok := c.tables[table]
+	return ok
+}
+
+// advance records a handled row update of a table.
+func (u *updater) advance(table string, seq uint64) {
+	if !u.healthy(table) {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	p, ok := u.progress[table]
+	if !ok {
+		return
+	}
+	if seq > p.handled {
+		p.handled = seq
+	}
+	p.check()
+}
+
+// probe sets the sequence number of the last row update of a table, published to topic, as the
+// target of its progress. It tries again with backoff until it succeeds, and the table stays
+// bootstrapping meanwhile, with the error in its health.
+func (u *updater) probe(table, topic string) {
+	bo := backoff.NewExponentialBackOff()
+	bo.MaxInterval = DefaultMaxProbeInterval
+	bo.MaxElapsedTime = 0
+
+	for {
+		seq, err := u.lastSequence(topic)
+
+		u.mu.Lock()
+		p, ok := u.progress[table]
+		if !ok {
+			u.mu.Unlock()
+			return
+		}
+		if err == nil {
+			p.target, p.known, p.probeErr = seq, true, nil
+			p.check()
+			u.mu.Unlock()
+			return
+		}
+		p.probeErr = err
+		u.mu.Unlock()
+
+		logger.Logger("client").Printf("failed to probe table %s: %s", table, err)
+
+		select {
+		case <-u.closed:
+			return
+		case <-time.After(bo.NextBackOff()):
+		}
+	}
+}
+
+// lastSequence returns the sequence number of the last message of a topic, or 0 if it has none. It
+// subscribes to the topic from its last message, which NATS Streaming delivers right away if there
+// is one, and assumes there is none if it is not delivered within DefaultCatchUpProbe.
+func (u *updater) lastSequence(topic string) (uint64, error) {
+	seqs := make(chan uint64, 1)
+	sub, err := u.nats.conn().Subscribe(topic, func(m *stan.Msg) {
+		select {
+		case seqs <- m.Sequence:
+		default:
+		}
+	}, stan.StartWithLastReceived(), stan.MaxInflight(1))
+	if err != nil {
+		return 0, fmt.Errorf("failed to subscribe to %s: %w", topic, err)
+	}
+	defer sub.Unsubscribe() //nolint // The subscription is only used to read the last message.
+
+	select {
+	case seq := <-seqs:
+		return seq, nil
+	case <-time.After(DefaultCatchUpProbe):
+		return 0, nil
+	}
+}
+
+// remove forgets the state of an unsubscribed table, and closes its watchers.
+func (u *updater) remove(table string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for w := range u.watchers[table] {
+		u.removeWatcher(w)
+	}
+	delete(u.watchers, table)
+	if sub, ok := u.replays[table]; ok {
+		sub.Unsubscribe() //nolint // The subscription of a lost connection is gone already.
+		delete(u.replays, table)
+	}
+	delete(u.health, table)
+	delete(u.progress, table)
+	delete(u.columns, table)
+	delete(u.filters, table)
+}
+
+// live reports whether a table is live.
+func (u *updater) live(table string) bool {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	p, ok := u.progress[table]
+	return ok && p.state() == SyncLive
+}
+
+// state returns the sync state of a table. u.mu must be held.
+func (p *progress) state() SyncState {
+	switch {
+	case p.stale:
+		return SyncStale
+	case !p.known:
+		return SyncBootstrapping
+	case p.handled < p.target:
+		return SyncCatchingUp
+	}
+	return SyncLive
+}
+
+// check closes the ready channel once the target is reached. u.mu must be held.
+func (p *progress) check() {
+	if p.state() != SyncLive {
+		return
+	}
+
+	select {
+	case <-p.ready:
+	default:
+		close(p.ready)
+	}
+}
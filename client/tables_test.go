@@ -0,0 +1,106 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hojulian/microdb/microdb"
+)
+
+// probeUpdater returns an updater connected to an embedded NATS Streaming server, and a function
+// publishing n messages to the topic test_table.
+func probeUpdater(t *testing.T, clientID string) (*updater, func(n int)) {
+	t.Helper()
+
+	host, port := runStreamingServer(t)
+	ns, err := dialSession(host, port, testClusterID, clientID)
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	t.Cleanup(func() { ns.close() })
+
+	sc, err := microdb.NATSConn(host, port, testClusterID, clientID+"-publisher", nil, nil)
+	if err != nil {
+		t.Fatalf("failed to connect publisher: %s", err)
+	}
+	t.Cleanup(func() { sc.Close() })
+
+	u, _ := testUpdater(t)
+	u.nats = ns
+
+	publish := func(n int) {
+		for i := 0; i < n; i++ {
+			if err := sc.Publish("test_table", []byte("test")); err != nil {
+				t.Fatalf("failed to publish: %s", err)
+			}
+		}
+	}
+
+	return u, publish
+}
+
+func TestLastSequence(t *testing.T) {
+	u, publish := probeUpdater(t, "client-last-sequence-test")
+
+	seq, err := u.lastSequence("test_table")
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint64(0), seq)
+	}
+
+	publish(3)
+	seq, err = u.lastSequence("test_table")
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint64(3), seq)
+	}
+
+	// The connection is lost.
+	u.nats.conn().Close()
+	_, err = u.lastSequence("test_table")
+	assert.Error(t, err)
+}
+
+func TestProbe(t *testing.T) {
+	u, publish := probeUpdater(t, "client-probe-test")
+	publish(3)
+	u.handler("test")
+
+	u.probe("test", "test_table")
+
+	h, _ := u.tableHealth("test")
+	assert.Equal(t, SyncCatchingUp, h.State)
+	assert.NoError(t, h.SyncError)
+
+	u.advance("test", 3)
+	assert.True(t, u.live("test"))
+}
+
+func TestProbeClosed(t *testing.T) {
+	u, _ := probeUpdater(t, "client-probe-closed-test")
+	u.nats.conn().Close()
+	u.handler("test")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		u.probe("test", "test_table")
+	}()
+
+	// The table is not assumed live while the last sequence number is unknown.
+	assert.Eventually(t, func() bool {
+		h, _ := u.tableHealth("test")
+		return h.SyncError != nil
+	}, time.Second, 10*time.Millisecond)
+
+	u.close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("probe did not stop once the client is closed")
+	}
+
+	h, _ := u.tableHealth("test")
+	assert.Equal(t, SyncBootstrapping, h.State)
+	assert.Error(t, h.SyncError)
+}
@@ -0,0 +1,572 @@
+package client //nolint // Package comment located in a different file.
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/stan.go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/hojulian/microdb/internal/logger"
+	pb "github.com/hojulian/microdb/internal/proto"
+	"github.com/hojulian/microdb/microdb"
+	mquery "github.com/hojulian/microdb/query"
+)
+
+// Applying row updates to local tables.
+
+// Policy represents what a client does with a row update it failed to apply to a local table.
+type Policy int
+
+const (
+	// PolicySkip skips the row update.
+	PolicySkip Policy = iota
+	// PolicyRetry applies the row update again with backoff, for up to a minute, then stops the
+	// table as PolicyStop does. It is retried in the background while the following row updates
+	// are applied, so it could be applied after later changes of the same row.
+	PolicyRetry
+	// PolicyStop stops applying row updates to the table and marks it unhealthy. Queries of
+	// unhealthy tables are sent to the data origin.
+	PolicyStop
+	// PolicyDeadLetter publishes the row update to the dead-letter topic of the table, along with
+	// the error, and skips it. The table is stopped if the row update could not be published. This
+	// is the default policy.
+	PolicyDeadLetter
+)
+
+const (
+	// DefaultPolicy is the policy of tables without one set.
+	DefaultPolicy = PolicyDeadLetter
+	// DefaultRetryTimeout is how long a row update is retried for with PolicyRetry.
+	DefaultRetryTimeout = time.Minute
+	// DefaultMaxRetryInterval is the maximum interval between retries of a row update.
+	DefaultMaxRetryInterval = 5 * time.Second
+)
+
+// UpdateError represents a row update that failed to apply to a local table.
+type UpdateError struct {
+	Table string
+	// Sequence is the sequence number of the row update in the table topic.
+	Sequence uint64
+	// Data is the original row update message.
+	Data   []byte
+	Policy Policy
+	Err    error
+}
+
+// Error returns the cause of the failure.
+func (e *UpdateError) Error() string {
+	return fmt.Sprintf("failed to apply row update %d of table %s: %s", e.Sequence, e.Table, e.Err)
+}
+
+// Unwrap returns the cause of the failure.
+func (e *UpdateError) Unwrap() error {
+	return e.Err
+}
+
+// TableHealth represents the state of a local table.
+type TableHealth struct {
+	Table string
+	// Healthy is false once the table is stopped, see PolicyStop.
+	Healthy bool
+	State   SyncState
+	// SyncError is why the sequence number of the last row update of a bootstrapping table could
+	// not be read yet, if it failed.
+	SyncError error
+	// Errors is the number of row updates that failed to apply.
+	Errors      uint64
+	LastError   error
+	LastErrorAt time.Time
+}
+
+// updater applies the row updates of subscribed tables to the local database.
+type updater struct {
+	reg  *microdb.Registry
+	db   *sql.DB
+	nats *natsSession
+
+	mu       sync.RWMutex
+	onError  func(*UpdateError)
+	policies map[string]Policy
+	health   map[string]*TableHealth
+	columns  map[string]*tableColumns
+	filters  map[string]*mquery.Filter
+	progress map[string]*progress
+	cache    *resultCache
+	watchers map[string]map[*watcher]struct{}
+	replays  map[string]*nats.Subscription
+	closed   chan struct{}
+}
+
+// tableColumns represents the columns of a local table, in the order of row update values.
+type tableColumns struct {
+	names []string
+	// keys are the indexes of the primary key columns.
+	keys []int
+
+	// source are the indexes of the columns in row updates, and width the number of values of row
+	// updates, if the table is projected. See Table.Columns.
+	source []int
+	width  int
+	// insert is the insert query of a projected table.
+	insert string
+}
+
+func newUpdater(reg *microdb.Registry, db *sql.DB, ns *natsSession) *updater {
+	log := logger.Logger("client")
+
+	return &updater{
+		reg:      reg,
+		db:       db,
+		nats:     ns,
+		onError:  func(e *UpdateError) { log.Print(e) },
+		policies: make(map[string]Policy),
+		health:   make(map[string]*TableHealth),
+		columns:  make(map[string]*tableColumns),
+		filters:  make(map[string]*mquery.Filter),
+		progress: make(map[string]*progress),
+		replays:  make(map[string]*nats.Subscription),
+		watchers: make(map[string]map[*watcher]struct{}),
+		closed:   make(chan struct{}),
+	}
+}
+
+// handler returns the subscription handler of a table.
+func (u *updater) handler(table string) stan.MsgHandler {
+	u.mu.Lock()
+	if _, ok := u.health[table]; !ok {
+		u.health[table] = &TableHealth{Table: table, Healthy: true}
+	}
+	if _, ok := u.progress[table]; !ok {
+		u.progress[table] = &progress{ready: make(chan struct{})}
+	}
+	u.mu.Unlock()
+
+	return func(m *stan.Msg) {
+		if !u.healthy(table) {
+			return
+		}
+
+		if err := u.apply(table, m.Sequence, m.Data); err != nil {
+			u.fail(table, m, err)
+		}
+		u.advance(table, m.Sequence)
+	}
+}
+
+// apply applies a row update to a local table, and notifies the watchers of the table once it is
+// committed.
+func (u *updater) apply(table string, seq uint64, data []byte) error {
+	var ru pb.RowUpdate
+	if err := proto.Unmarshal(data, &ru); err != nil {
+		return fmt.Errorf("failed to parse row update: %w", err)
+	}
+
+	cols, err := u.tableColumns(table)
+	if err != nil {
+		return err
+	}
+
+	tx, err := u.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to create update transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint // Rolling back a committed transaction is a no-op.
+
+	row, err := cols.project(pb.UnmarshalValues(ru.GetRow()))
+	if err != nil {
+		return fmt.Errorf("%w, got: %s", err, ru.String())
+	}
+	old, err := cols.project(pb.UnmarshalValues(ru.GetOldRow()))
+	if err != nil {
+		return fmt.Errorf("%w, got: %s", err, ru.String())
+	}
+	e := newChangeEvent(table, seq, &ru, cols, old, row)
+
+	switch ru.GetOp() {
+	case pb.RowOp_ROW_OP_DELETE:
+		n, err := deleteRow(tx, table, cols, row)
+		if err != nil {
+			return err
+		}
+		if n == 0 && u.filter(table) != nil {
+			// The row did not match the filter of the table.
+			e = nil
+		}
+
+	default:
+		if e, err = u.filterEvent(e); err != nil {
+			return fmt.Errorf("%w, got: %s", err, ru.String())
+		}
+
+		// The primary key could be updated too, or the row could no longer match the filter of
+		// the table.
+		var n int64
+		if len(old) > 0 {
+			if n, err = deleteRow(tx, table, cols, old); err != nil {
+				return err
+			}
+		}
+
+		if e != nil && e.Op == OpDelete {
+			if len(old) == 0 {
+				if n, err = deleteRow(tx, table, cols, row); err != nil {
+					return err
+				}
+			}
+			if n == 0 {
+				e = nil
+			}
+			break
+		}
+
+		if err := u.insertRow(tx, table, cols, row); err != nil {
+			return fmt.Errorf("%w, got: %s", err, ru.String())
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed commit update to table: %w", err)
+	}
+
+	u.applied(table, ru.GetCommittedAt())
+	u.resultCache().invalidate(table)
+	if e != nil {
+		u.notify(e)
+	}
+
+	return nil
+}
+
+func (u *updater) insertRow(tx *sql.Tx, table string, cols *tableColumns, row []interface{}) error {
+	iq := cols.insert
+	if iq == "" {
+		var err error
+		if iq, err = u.reg.InsertQuery(table); err != nil {
+			return fmt.Errorf("failed to get insert query: %w", err)
+		}
+	}
+
+	r, err := tx.Exec(iq, row...)
+	if err != nil {
+		return fmt.Errorf("failed to update local databse for table %s: %w", table, err)
+	}
+
+	if ra, err := r.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update table: %w", err)
+	} else if ra == 0 {
+		return errors.New("failed to update table: no rows affected")
+	}
+
+	return nil
+}
+
+// deleteRow deletes a row from a local table by its primary key, or by all its columns if the table
+// has none, and returns the number of rows deleted. Deleting a missing row is not an error.
+func deleteRow(tx *sql.Tx, table string, cols *tableColumns, row []interface{}) (int64, error) {
+	if len(row) != len(cols.names) {
+		return 0, fmt.Errorf("failed to delete row: got %d values, table %s has %d columns",
+			len(row), table, len(cols.names))
+	}
+
+	keys := cols.keys
+	if len(keys) == 0 {
+		keys = make([]int, len(cols.names))
+		for i := range keys {
+			keys[i] = i
+		}
+	}
+
+	conds := make([]string, 0, len(keys))
+	args := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		conds = append(conds, fmt.Sprintf("%s IS ?", quoteIdent(cols.names[k])))
+		args = append(args, row[k])
+	}
+
+	q := fmt.Sprintf("DELETE FROM %s WHERE %s", quoteIdent(table), strings.Join(conds, " AND "))
+	r, err := tx.Exec(q, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete row of table %s: %w", table, err)
+	}
+
+	n, err := r.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete row of table %s: %w", table, err)
+	}
+
+	return n, nil
+}
+
+// tableColumns returns the columns of a local table.
+func (u *updater) tableColumns(table string) (*tableColumns, error) {
+	u.mu.RLock()
+	cols, ok := u.columns[table]
+	u.mu.RUnlock()
+	if ok {
+		return cols, nil
+	}
+
+	info, err := tableInfo(u.db, table)
+	if err != nil {
+		return nil, err
+	}
+
+	cols = &tableColumns{}
+	pks := make(map[int]int)
+	for i, c := range info {
+		if c.pk > 0 {
+			pks[c.pk] = i
+		}
+		cols.names = append(cols.names, c.name)
+	}
+	for i := 1; i <= len(pks); i++ {
+		cols.keys = append(cols.keys, pks[i])
+	}
+
+	u.mu.Lock()
+	u.columns[table] = cols
+	u.mu.Unlock()
+
+	return cols, nil
+}
+
+// columnInfo represents a column of a local table.
+type columnInfo struct {
+	name    string
+	typ     string
+	notNull bool
+	dflt    sql.NullString
+	// pk is the position of the column in the primary key, or 0.
+	pk int
+}
+
+// tableInfo returns the columns of a local table, in order.
+func tableInfo(db *sql.DB, table string) ([]columnInfo, error) {
+	rs, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", quoteIdent(table)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of table %s: %w", table, err)
+	}
+	defer rs.Close()
+
+	var cs []columnInfo
+	for rs.Next() {
+		var (
+			cid int
+			c   columnInfo
+		)
+		if err := rs.Scan(&cid, &c.name, &c.typ, &c.notNull, &c.dflt, &c.pk); err != nil {
+			return nil, fmt.Errorf("failed to read columns of table %s: %w", table, err)
+		}
+		cs = append(cs, c)
+	}
+	if err := rs.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read columns of table %s: %w", table, err)
+	}
+
+	return cs, nil
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// fail handles a row update that failed to apply according to the policy of its table.
+func (u *updater) fail(table string, m *stan.Msg, err error) {
+	ue := &UpdateError{
+		Table:    table,
+		Sequence: m.Sequence,
+		Data:     m.Data,
+		Policy:   u.policy(table),
+		Err:      err,
+	}
+
+	stop := false
+	switch ue.Policy {
+	case PolicySkip:
+
+	case PolicyRetry:
+		// Retrying in the handler would hold back the row updates that follow.
+		go u.retry(ue)
+		return
+
+	case PolicyStop:
+		stop = true
+
+	case PolicyDeadLetter:
+		if derr := u.deadLetter(ue); derr != nil {
+			ue.Err = fmt.Errorf("%s, and %w", ue.Err, derr)
+			stop = true
+		}
+	}
+
+	u.failed(ue, stop)
+}
+
+// retry applies a failed row update again with backoff, for up to a minute, then stops the table.
+// It gives up once the table is stopped, removed or the client is closed.
+func (u *updater) retry(ue *UpdateError) {
+	bo := backoff.NewExponentialBackOff()
+	bo.MaxInterval = DefaultMaxRetryInterval
+	bo.MaxElapsedTime = DefaultRetryTimeout
+
+	for d := bo.NextBackOff(); d != backoff.Stop; d = bo.NextBackOff() {
+		select {
+		case <-u.closed:
+			return
+		case <-time.After(d):
+		}
+		if !u.healthy(ue.Table) {
+			return
+		}
+
+		err := u.apply(ue.Table, ue.Sequence, ue.Data)
+		if err == nil {
+			return
+		}
+		ue.Err = err
+	}
+
+	u.failed(ue, true)
+}
+
+// failed records a failed row update in the health of its table, stopping the table if stop is
+// set, and passes it to the error handler.
+func (u *updater) failed(ue *UpdateError, stop bool) {
+	u.mu.Lock()
+	h, ok := u.health[ue.Table]
+	if !ok {
+		// The table was removed while the row update was retried.
+		u.mu.Unlock()
+		return
+	}
+	h.Errors++
+	h.LastError = ue.Err
+	h.LastErrorAt = time.Now()
+	if stop {
+		h.Healthy = false
+	}
+	onError := u.onError
+	u.mu.Unlock()
+
+	if onError != nil {
+		onError(ue)
+	}
+}
+
+func (u *updater) deadLetter(ue *UpdateError) error {
+	do, err := u.reg.GetDataOrigin(ue.Table)
+	if err != nil {
+		return fmt.Errorf("failed to get data origin for table: %w", err)
+	}
+
+	p, err := proto.Marshal(&pb.DeadLetter{
+		Table:    ue.Table,
+		Data:     ue.Data,
+		Error:    ue.Err.Error(),
+		Sequence: ue.Sequence,
+		FailedAt: timestamppb.Now(),
+		ClientId: u.nats.clientID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+
+	if err := u.nats.conn().Publish(do.DeadLetterTopic(), p); err != nil {
+		return fmt.Errorf("failed to publish dead letter: %w", err)
+	}
+
+	return nil
+}
+
+func (u *updater) policy(table string) Policy {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	if p, ok := u.policies[table]; ok {
+		return p
+	}
+	return DefaultPolicy
+}
+
+func (u *updater) healthy(table string) bool {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	h, ok := u.health[table]
+	return ok && h.Healthy
+}
+
+func (u *updater) tableHealth(table string) (TableHealth, bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	h, ok := u.health[table]
+	if !ok {
+		return TableHealth{}, false
+	}
+	return u.withState(h), true
+}
+
+// withState returns a copy of the state of a table, with its sync state. u.mu must be held.
+func (u *updater) withState(h *TableHealth) TableHealth {
+	c := *h
+	if p, ok := u.progress[h.Table]; ok {
+		c.State = p.state()
+		c.SyncError = p.probeErr
+	}
+	return c
+}
+
+func (u *updater) allHealth() []TableHealth {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	hs := make([]TableHealth, 0, len(u.health))
+	for _, h := range u.health {
+		hs = append(hs, u.withState(h))
+	}
+	sort.Slice(hs, func(i, j int) bool { return hs[i].Table < hs[j].Table })
+
+	return hs
+}
+
+// SetErrorHandler sets the function called with every row update that failed to apply to a local
+// table, after its policy is applied. By default, errors are logged.
+func (c *Client) SetErrorHandler(h func(*UpdateError)) {
+	c.updater.mu.Lock()
+	defer c.updater.mu.Unlock()
+
+	c.updater.onError = h
+}
+
+// SetTablePolicy sets what the client does with row updates of a table that failed to apply.
+func (c *Client) SetTablePolicy(table string, p Policy) {
+	c.updater.mu.Lock()
+	defer c.updater.mu.Unlock()
+
+	c.updater.policies[table] = p
+}
+
+// TableHealth returns the state of a subscribed table.
+func (c *Client) TableHealth(table string) (TableHealth, error) {
+	h, ok := c.updater.tableHealth(table)
+	if !ok {
+		return TableHealth{}, fmt.Errorf("table is not subscribed, got: %s", table)
+	}
+
+	return h, nil
+}
+
+// Health returns the state of all subscribed tables, sorted by table name.
+func (c *Client) Health() []TableHealth {
+	return c.updater.allHealth()
+}
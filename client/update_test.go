@@ -0,0 +1,92 @@
+package client
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/stan.go"
+	stanpb "github.com/nats-io/stan.go/pb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+)
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name string
+		// fix runs once the row update failed, and reports whether it is applied once retried.
+		fix  func(t *testing.T, u *updater) bool
+		want [][]interface{}
+	}{
+		{
+			name: "applied",
+			fix: func(t *testing.T, u *updater) bool {
+				_, err := u.db.Exec("ALTER TABLE moved RENAME TO test")
+				assert.NoError(t, err)
+				return true
+			},
+			want: [][]interface{}{{int64(1), "a", int64(1)}},
+		},
+		{
+			name: "client closed",
+			fix: func(t *testing.T, u *updater) bool {
+				u.close()
+				_, err := u.db.Exec("ALTER TABLE moved RENAME TO test")
+				assert.NoError(t, err)
+				return false
+			},
+		},
+		{
+			name: "table stopped",
+			fix: func(t *testing.T, u *updater) bool {
+				u.mu.Lock()
+				u.health["test"].Healthy = false
+				u.mu.Unlock()
+				_, err := u.db.Exec("ALTER TABLE moved RENAME TO test")
+				assert.NoError(t, err)
+				return false
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := testUpdater(t)
+			u.policies["test"] = PolicyRetry
+			var reported int32
+			u.onError = func(*UpdateError) { atomic.AddInt32(&reported, 1) }
+			h := u.handler("test")
+
+			// The row update fails until the local table is back.
+			if _, err := u.db.Exec("ALTER TABLE test RENAME TO moved"); err != nil {
+				t.Fatalf("failed to rename table: %s", err)
+			}
+			p, err := proto.Marshal(&pb.RowUpdate{
+				Op:  pb.RowOp_ROW_OP_INSERT,
+				Row: pb.MarshalValues([]interface{}{int64(1), "a", int64(1)}),
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			// The handler does not wait for the row update to be retried.
+			start := time.Now()
+			h(&stan.Msg{MsgProto: stanpb.MsgProto{Sequence: 1, Data: p}})
+			assert.Less(t, int64(time.Since(start)), int64(100*time.Millisecond))
+
+			if tt.fix(t, u) {
+				assert.Eventually(t, func() bool {
+					return len(rows(t, u.db, "test", 3)) > 0
+				}, 5*time.Second, 10*time.Millisecond)
+			} else {
+				// Retries would apply it within the first intervals of the backoff.
+				time.Sleep(2 * time.Second)
+			}
+
+			assert.Equal(t, tt.want, rows(t, u.db, "test", 3))
+			assert.Equal(t, int32(0), atomic.LoadInt32(&reported))
+		})
+	}
+}
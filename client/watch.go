@@ -0,0 +1,203 @@
+package client //nolint // Package comment located in a different file.
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+)
+
+// Change notifications.
+
+// DefaultWatchBuffer is the number of change events buffered for each watcher.
+const DefaultWatchBuffer = 256
+
+// Op represents the kind of change of a row.
+type Op int
+
+// Row change kinds.
+const (
+	// OpUpsert represents a row inserted or replaced by a publisher that does not report the
+	// kind of change.
+	OpUpsert Op = iota
+	OpInsert
+	OpUpdate
+	OpDelete
+)
+
+// String returns the name of the op.
+func (o Op) String() string {
+	switch o {
+	case OpInsert:
+		return "insert"
+	case OpUpdate:
+		return "update"
+	case OpDelete:
+		return "delete"
+	default:
+		return "upsert"
+	}
+}
+
+// ChangeEvent represents a change of a row of a local table, by column name.
+type ChangeEvent struct {
+	Table string
+	// Sequence is the sequence number of the row update in the table topic.
+	Sequence uint64
+	Op       Op
+	// Old is the row before an update, or the deleted row. It is nil for inserts and upserts.
+	Old map[string]interface{}
+	// New is the row after an insert, update or upsert. It is nil for deletes.
+	New map[string]interface{}
+}
+
+// WatchFilter reports whether a change event is sent to a watcher.
+type WatchFilter func(*ChangeEvent) bool
+
+// watcher receives the change events of a table.
+type watcher struct {
+	table  string
+	filter WatchFilter
+	ch     chan *ChangeEvent
+}
+
+// Watch returns a channel receiving the changes of a subscribed table accepted by filter, or all of
+// them if filter is nil, once they are committed to the local table.
+//
+// The channel is closed once ctx is done or the client is closed. It is also closed if the
+// receiver falls behind by more than DefaultWatchBuffer events, so that the local table keeps
+// being updated. The receiver could then read the table again and watch it anew.
+func (c *Client) Watch(ctx context.Context, table string, filter WatchFilter) (<-chan *ChangeEvent, error) {
+	if !c.subscribed(table) {
+		return nil, fmt.Errorf("table is not subscribed, got: %s", table)
+	}
+
+	w := &watcher{
+		table:  table,
+		filter: filter,
+		ch:     make(chan *ChangeEvent, DefaultWatchBuffer),
+	}
+	c.updater.watch(w)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-c.updater.closed:
+		}
+		c.updater.unwatch(w)
+	}()
+
+	return w.ch, nil
+}
+
+func (u *updater) watch(w *watcher) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	ws, ok := u.watchers[w.table]
+	if !ok {
+		ws = make(map[*watcher]struct{})
+		u.watchers[w.table] = ws
+	}
+	ws[w] = struct{}{}
+}
+
+func (u *updater) unwatch(w *watcher) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.removeWatcher(w)
+}
+
+// removeWatcher removes a watcher and closes its channel. u.mu must be held.
+func (u *updater) removeWatcher(w *watcher) {
+	if _, ok := u.watchers[w.table][w]; !ok {
+		return
+	}
+	delete(u.watchers[w.table], w)
+	close(w.ch)
+}
+
+// close removes all watchers, once the client is closed.
+func (u *updater) close() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	select {
+	case <-u.closed:
+	default:
+		close(u.closed)
+	}
+
+	for _, ws := range u.watchers {
+		for w := range ws {
+			u.removeWatcher(w)
+		}
+	}
+}
+
+// notify sends a change event to the watchers of its table. Filters are called without holding the
+// lock, so that they could use the client.
+func (u *updater) notify(e *ChangeEvent) {
+	u.mu.RLock()
+	ws := make([]*watcher, 0, len(u.watchers[e.Table]))
+	for w := range u.watchers[e.Table] {
+		ws = append(ws, w)
+	}
+	u.mu.RUnlock()
+
+	for _, w := range ws {
+		if w.filter != nil && !w.filter(e) {
+			continue
+		}
+
+		u.mu.Lock()
+		if _, ok := u.watchers[e.Table][w]; ok {
+			select {
+			case w.ch <- e:
+			default:
+				// Slow receivers must not block updates of the table.
+				u.removeWatcher(w)
+			}
+		}
+		u.mu.Unlock()
+	}
+}
+
+func newChangeEvent(table string, seq uint64, ru *pb.RowUpdate, cols *tableColumns,
+	old, row []interface{}) *ChangeEvent {
+	e := &ChangeEvent{Table: table, Sequence: seq}
+
+	switch ru.GetOp() {
+	case pb.RowOp_ROW_OP_INSERT:
+		e.Op = OpInsert
+		e.New = cols.row(row)
+	case pb.RowOp_ROW_OP_UPDATE:
+		e.Op = OpUpdate
+		e.Old = cols.row(old)
+		e.New = cols.row(row)
+	case pb.RowOp_ROW_OP_DELETE:
+		e.Op = OpDelete
+		e.Old = cols.row(row)
+	default:
+		e.Op = OpUpsert
+		e.New = cols.row(row)
+	}
+
+	return e
+}
+
+// row maps the values of a row to their column names.
+func (c *tableColumns) row(vs []interface{}) map[string]interface{} {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	r := make(map[string]interface{}, len(vs))
+	for i, v := range vs {
+		if i < len(c.names) {
+			r[c.names[i]] = v
+		}
+	}
+	return r
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/stan.go"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+)
+
+func TestNewChangeEvent(t *testing.T) {
+	cols := &tableColumns{names: []string{"id", "name"}}
+	old := []interface{}{int64(1), "a"}
+	row := []interface{}{int64(1), "b"}
+
+	testCases := []struct {
+		desc string
+		op   pb.RowOp
+		want *ChangeEvent
+	}{
+		{
+			desc: "insert",
+			op:   pb.RowOp_ROW_OP_INSERT,
+			want: &ChangeEvent{Table: "test", Sequence: 2, Op: OpInsert,
+				New: map[string]interface{}{"id": int64(1), "name": "b"}},
+		},
+		{
+			desc: "update",
+			op:   pb.RowOp_ROW_OP_UPDATE,
+			want: &ChangeEvent{Table: "test", Sequence: 2, Op: OpUpdate,
+				Old: map[string]interface{}{"id": int64(1), "name": "a"},
+				New: map[string]interface{}{"id": int64(1), "name": "b"}},
+		},
+		{
+			desc: "delete",
+			op:   pb.RowOp_ROW_OP_DELETE,
+			// The deleted row is the row of the row update.
+			want: &ChangeEvent{Table: "test", Sequence: 2, Op: OpDelete,
+				Old: map[string]interface{}{"id": int64(1), "name": "b"}},
+		},
+		{
+			desc: "unspecified",
+			op:   pb.RowOp_ROW_OP_UNSPECIFIED,
+			want: &ChangeEvent{Table: "test", Sequence: 2, Op: OpUpsert,
+				New: map[string]interface{}{"id": int64(1), "name": "b"}},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			e := newChangeEvent("test", 2, &pb.RowUpdate{Op: tC.op}, cols, old, row)
+			assert.Equal(t, tC.want, e)
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	update := func(t *testing.T, u *updater, seq uint64, ru *pb.RowUpdate) {
+		t.Helper()
+
+		p, err := proto.Marshal(ru)
+		if err != nil {
+			t.Fatalf("failed to marshal row update: %s", err)
+		}
+		if err := u.apply("test", seq, p); err != nil {
+			t.Fatalf("failed to apply row update: %s", err)
+		}
+	}
+	insert := func(id int64, name string) *pb.RowUpdate {
+		return &pb.RowUpdate{
+			Op:  pb.RowOp_ROW_OP_INSERT,
+			Row: pb.MarshalValues([]interface{}{id, name, int64(1)}),
+		}
+	}
+	client := func(t *testing.T) (*Client, *updater) {
+		u, _ := testUpdater(t)
+		return &Client{tables: map[string]stan.Subscription{"test": nil}, updater: u}, u
+	}
+
+	t.Run("delivery", func(t *testing.T) {
+		c, u := client(t)
+		ch, err := c.Watch(context.Background(), "test", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		update(t, u, 1, insert(1, "a"))
+		update(t, u, 2, &pb.RowUpdate{
+			Op:     pb.RowOp_ROW_OP_UPDATE,
+			OldRow: pb.MarshalValues([]interface{}{int64(1), "a", int64(1)}),
+			Row:    pb.MarshalValues([]interface{}{int64(1), "b", int64(1)}),
+		})
+		update(t, u, 3, &pb.RowUpdate{
+			Op:  pb.RowOp_ROW_OP_DELETE,
+			Row: pb.MarshalValues([]interface{}{int64(1), "b", int64(1)}),
+		})
+
+		var got []Op
+		for i := 0; i < 3; i++ {
+			e := <-ch
+			assert.Equal(t, uint64(i+1), e.Sequence)
+			got = append(got, e.Op)
+		}
+		assert.Equal(t, []Op{OpInsert, OpUpdate, OpDelete}, got)
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		c, u := client(t)
+		ch, err := c.Watch(context.Background(), "test", func(e *ChangeEvent) bool {
+			return e.New["name"] == "b"
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		update(t, u, 1, insert(1, "a"))
+		update(t, u, 2, insert(2, "b"))
+
+		e := <-ch
+		assert.Equal(t, uint64(2), e.Sequence)
+		assert.Len(t, ch, 0)
+	})
+
+	t.Run("unsubscribed table", func(t *testing.T) {
+		c, _ := client(t)
+		_, err := c.Watch(context.Background(), "other", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("fallen behind", func(t *testing.T) {
+		c, u := client(t)
+		slow, err := c.Watch(context.Background(), "test", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		fast, err := c.Watch(context.Background(), "test", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		for i := 0; i <= DefaultWatchBuffer; i++ {
+			update(t, u, uint64(i+1), insert(int64(i), "a"))
+			<-fast
+		}
+
+		// The slow watcher gets the buffered events, then its channel is closed.
+		n := 0
+		for range slow {
+			n++
+		}
+		assert.Equal(t, DefaultWatchBuffer, n)
+
+		update(t, u, DefaultWatchBuffer+2, insert(-1, "a"))
+		assert.Equal(t, uint64(DefaultWatchBuffer+2), (<-fast).Sequence)
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		c, _ := client(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ch, err := c.Watch(ctx, "test", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		cancel()
+		select {
+		case _, ok := <-ch:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			t.Error("channel is not closed")
+		}
+	})
+
+	t.Run("client closed", func(t *testing.T) {
+		c, u := client(t)
+		ch, err := c.Watch(context.Background(), "test", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		u.close()
+		select {
+		case _, ok := <-ch:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			t.Error("channel is not closed")
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/hojulian/microdb/microdb"
+)
+
+// configCheck validates a data origin config file and optionally tests connectivity to each
+// data origin.
+func configCheck(args []string) error {
+	fs := flag.NewFlagSet("config check", flag.ContinueOnError)
+	connect := fs.Bool("connect", false, "test connectivity to each data origin")
+	timeout := fs.Duration("timeout", 10*time.Second, "connectivity test timeout per data origin")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: microdb config check [-connect] [-timeout duration] <file>")
+	}
+	name := fs.Arg(0)
+
+	cfg, err := microdb.LoadCfg(name)
+	if err != nil {
+		var errs microdb.CfgErrors
+		if errors.As(err, &errs) {
+			for _, e := range errs {
+				fmt.Fprintf(os.Stdout, "%s:%s\n", name, e)
+			}
+			return fmt.Errorf("%s: %d problem(s) found", name, len(errs))
+		}
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	tables := make([]string, 0, len(cfg.Origins))
+	for t := range cfg.Origins {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+
+	fmt.Fprintf(os.Stdout, "%s: %d table(s) ok\n", name, len(tables))
+	if !*connect {
+		return nil
+	}
+
+	var failed int
+	for _, t := range tables {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		err := cfg.Origins[t].Ping(ctx)
+		cancel()
+
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stdout, "%s: connection failed: %s\n", t, err)
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "%s: connection ok\n", t)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d data origin(s) unreachable", failed)
+	}
+
+	return nil
+}
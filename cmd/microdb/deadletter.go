@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/stan.go"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+	"github.com/hojulian/microdb/microdb"
+)
+
+// deadLetterFlags are the flags shared by dead-letter commands.
+type deadLetterFlags struct {
+	host      *string
+	port      *string
+	clusterID *string
+	clientID  *string
+	from      *uint64
+	to        *uint64
+	wait      *time.Duration
+}
+
+func newDeadLetterFlags(fs *flag.FlagSet) *deadLetterFlags {
+	cfg := microdb.NATSCfgFromEnv()
+
+	return &deadLetterFlags{
+		host:      fs.String("host", cfg.Host, "NATS host"),
+		port:      fs.String("port", cfg.Port, "NATS port"),
+		clusterID: fs.String("cluster", cfg.ClusterID, "NATS streaming cluster ID, NATS_CLUSTER_ID by default"),
+		clientID:  fs.String("client", "microdb-deadletter", "NATS streaming client ID"),
+		from:      fs.Uint64("from", 1, "first dead letter sequence"),
+		to:        fs.Uint64("to", 0, "last dead letter sequence, 0 for the latest"),
+		wait:      fs.Duration("wait", 2*time.Second, "time to wait for more dead letters"),
+	}
+}
+
+// deadLetterInspect prints the dead letters of a table.
+func deadLetterInspect(args []string) error {
+	fs := flag.NewFlagSet("deadletter inspect", flag.ContinueOnError)
+	f := newDeadLetterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: microdb deadletter inspect [flags] <table>")
+	}
+	do := topics(fs.Arg(0))
+
+	sc, err := f.connect()
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+
+	var n int
+	err = read(sc, do, *f.from, *f.to, *f.wait, func(seq uint64, dl *pb.DeadLetter) error {
+		n++
+		fmt.Fprintf(os.Stdout, "%d: update %d failed on client %s at %s: %s\n", seq, dl.GetSequence(),
+			dl.GetClientId(), dl.GetFailedAt().AsTime().Format(time.RFC3339), dl.GetError())
+
+		var ru pb.RowUpdate
+		if err := proto.Unmarshal(dl.GetData(), &ru); err != nil {
+			fmt.Fprintf(os.Stdout, "\tinvalid row update: %q\n", dl.GetData())
+			return nil
+		}
+		fmt.Fprintf(os.Stdout, "\trow: %v\n", pb.UnmarshalValues(ru.GetRow()))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "%s: %d dead letter(s)\n", fs.Arg(0), n)
+	return nil
+}
+
+// deadLetterReplay sends the dead letters of a table to the clients that failed to apply them, which
+// read the rows of the dead letters from the data origin again. Other clients are not affected.
+func deadLetterReplay(args []string) error {
+	fs := flag.NewFlagSet("deadletter replay", flag.ContinueOnError)
+	f := newDeadLetterFlags(fs)
+	target := fs.String("target", "", "only replay the dead letters of this NATS client ID")
+	timeout := fs.Duration("timeout", 15*time.Second, "time to wait for a client to apply a dead letter")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: microdb deadletter replay [flags] <table>")
+	}
+	do := topics(fs.Arg(0))
+
+	sc, err := f.connect()
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+
+	var n, failed int
+	err = read(sc, do, *f.from, *f.to, *f.wait, func(seq uint64, dl *pb.DeadLetter) error {
+		if *target != "" && dl.GetClientId() != *target {
+			return nil
+		}
+
+		if err := replay(sc.NatsConn(), do, dl, *timeout); err != nil {
+			failed++
+			fmt.Fprintf(os.Stdout, "%d: %v\n", seq, err)
+			return nil
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "%s: %d dead letter(s) replayed\n", fs.Arg(0), n)
+	if failed > 0 {
+		return fmt.Errorf("failed to replay %d dead letter(s)", failed)
+	}
+	return nil
+}
+
+// replay sends a dead letter to the client that failed to apply it, and waits for it to be applied.
+func replay(nc *nats.Conn, do *microdb.DataOrigin, dl *pb.DeadLetter, timeout time.Duration) error {
+	if dl.GetClientId() == "" {
+		return errors.New("dead letter has no client ID")
+	}
+
+	p, err := proto.Marshal(dl)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+
+	m, err := nc.Request(do.ReplayTopic(dl.GetClientId()), p, timeout)
+	if err != nil {
+		return fmt.Errorf("failed to replay dead letter to client %s: %w", dl.GetClientId(), err)
+	}
+	if len(m.Data) > 0 {
+		return fmt.Errorf("client %s failed to replay dead letter: %s", dl.GetClientId(), m.Data)
+	}
+
+	return nil
+}
+
+func (f *deadLetterFlags) connect() (stan.Conn, error) {
+	if *f.clusterID == "" {
+		return nil, errors.New("missing NATS streaming cluster ID, set -cluster or NATS_CLUSTER_ID")
+	}
+
+	sc, err := microdb.NATSConn(*f.host, *f.port, *f.clusterID, *f.clientID, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	return sc, nil
+}
+
+// read calls fn with the dead letters of a table from sequence from to sequence to, or until there
+// are no more of them for the wait duration.
+func read(sc stan.Conn, do *microdb.DataOrigin, from, to uint64, wait time.Duration,
+	fn func(uint64, *pb.DeadLetter) error) error {
+	msgs := make(chan *stan.Msg, 64)
+	sub, err := sc.Subscribe(do.DeadLetterTopic(), func(m *stan.Msg) { msgs <- m },
+		stan.StartAtSequence(from))
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to dead letters: %w", err)
+	}
+	defer sub.Close() //nolint // Closing a non-durable subscription only fails if disconnected.
+
+	for {
+		select {
+		case m := <-msgs:
+			if to != 0 && m.Sequence > to {
+				return nil
+			}
+
+			var dl pb.DeadLetter
+			if err := proto.Unmarshal(m.Data, &dl); err != nil {
+				return fmt.Errorf("failed to parse dead letter %d: %w", m.Sequence, err)
+			}
+			if err := fn(m.Sequence, &dl); err != nil {
+				return err
+			}
+
+		case <-time.After(wait):
+			return nil
+		}
+	}
+}
+
+// topics returns a data origin for resolving the topics of a table.
+func topics(table string) *microdb.DataOrigin {
+	return &microdb.DataOrigin{Schema: &microdb.Schema{Table: table}}
+}
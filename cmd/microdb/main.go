@@ -0,0 +1,46 @@
+// Package main contains the MicroDB command line tool.
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const usage = `usage: microdb <command> [arguments]
+
+commands:
+  config check [-connect] [-timeout duration] <file>
+        validate a data origin config file
+  deadletter inspect [flags] <table>
+        print the row updates of a table that clients failed to apply
+  deadletter replay [flags] <table>
+        have the clients that failed to apply row updates of a table read their rows again
+`
+
+type command func(args []string) error
+
+//nolint // Command table used for dispatching.
+var commands = map[string]command{
+	"config check":       configCheck,
+	"deadletter inspect": deadLetterInspect,
+	"deadletter replay":  deadLetterReplay,
+}
+
+func main() {
+	for name, cmd := range commands {
+		words := strings.Fields(name)
+		if len(os.Args) <= len(words) || strings.Join(os.Args[1:len(words)+1], " ") != name {
+			continue
+		}
+
+		if err := cmd(os.Args[len(words)+1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprint(os.Stderr, usage)
+	os.Exit(2)
+}
@@ -2,50 +2,106 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"math"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nats-io/stan.go"
+	"github.com/siddontang/go-mysql/mysql"
 
 	"github.com/hojulian/microdb/internal/logger"
 	"github.com/hojulian/microdb/microdb"
 	"github.com/hojulian/microdb/publisher"
 )
 
+const (
+	// reloadInterval is how often the data origin config file is checked for changes.
+	reloadInterval = 5 * time.Second
+	// serverIDsPerPublisher is the number of replication server IDs reserved for each publisher ID,
+	// i.e. publisher N uses the server IDs from N*1000 to N*1000+999, one per data origin.
+	serverIDsPerPublisher = 1000
+)
+
+// server publishes every table in the data origin config, with one publisher per data origin
+// connection.
+type server struct {
+	log *log.Logger
+	sc  stan.Conn
+	reg *microdb.Registry
+	id  uint32
+	// lease is nil if leader election is disabled.
+	lease *publisher.LeaseCfg
+
+	// origins maps a data origin connection to its publisher.
+	origins map[string]*origin
+	// errs receives the errors of publishers that stopped on their own.
+	errs chan originErr
+}
+
+type origin struct {
+	id     uint32
+	tables []string
+	h      publisher.Handler
+	done   chan struct{}
+}
+
+type originErr struct {
+	o   *origin
+	err error
+}
+
 func main() {
 	var (
 		log            = logger.Logger("publisher")
-		natsHost       = os.Getenv("NATS_HOST")
-		natsPort       = os.Getenv("NATS_PORT")
-		natsClusterID  = os.Getenv("NATS_CLUSTER_ID")
-		mysqlHost      = os.Getenv("MYSQL_HOST")
-		mysqlPort      = os.Getenv("MYSQL_PORT")
-		mysqlUser      = os.Getenv("MYSQL_USER")
-		mysqlPassword  = os.Getenv("MYSQL_PASSWORD")
-		mysqlDatabase  = os.Getenv("MYSQL_DATABASE")
-		mysqlTables    = os.Getenv("MYSQL_TABLES")
+		natsCfg        = microdb.NATSCfgFromEnv()
 		dataOriginPath = os.Getenv("DATAORIGIN_CFG")
 		id             = os.Getenv("PUBLISHER_ID")
+		leaseTTL       = os.Getenv("PUBLISHER_LEASE_TTL")
 	)
 
 	if id == "" {
 		log.Fatalf("empty publisher ID")
 	}
-	pid, err := strconv.ParseInt(id, 10, 32)
+	pid, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
 		log.Fatalf("publisher ID must be an integer")
 	}
-	tables := parseTablesString(mysqlTables)
+	if pid == 0 || pid > math.MaxUint32/serverIDsPerPublisher-1 {
+		log.Fatalf("publisher ID must be between 1 and %d", math.MaxUint32/serverIDsPerPublisher-1)
+	}
+
+	// Redundant publishers of the same tables elect a leader if a lease TTL is set.
+	var lease *publisher.LeaseCfg
+	if leaseTTL != "" {
+		ttl, err := time.ParseDuration(leaseTTL)
+		if err != nil {
+			log.Fatalf("invalid publisher lease TTL: %v", err)
+		}
 
-	if err = microdb.AddDataOriginFromCfg(dataOriginPath); err != nil {
+		host, err := os.Hostname()
+		if err != nil {
+			log.Fatalf("failed to get hostname: %v", err)
+		}
+		lease = &publisher.LeaseCfg{Holder: fmt.Sprintf("%s-%d", host, pid), TTL: ttl}
+	}
+
+	reg := microdb.DefaultRegistry()
+	if err = reg.AddDataOriginFromCfg(dataOriginPath); err != nil {
 		log.Fatalf("failed to parse data origin configs: %v", err)
 	}
 
 	sc, err := microdb.NATSConn(
-		natsHost,
-		natsPort,
-		natsClusterID,
-		fmt.Sprintf("publisher-%s-%d", strings.Join(tables, "-"), pid),
+		natsCfg.Host,
+		natsCfg.Port,
+		natsCfg.ClusterID,
+		fmt.Sprintf("publisher-%d", pid),
 		nil,
 		nil,
 	)
@@ -53,33 +109,240 @@ func main() {
 		log.Fatalf("failed to create nats connection: %v", err)
 	}
 
-	h, err := publisher.MySQLHandler(
-		mysqlHost,
-		mysqlPort,
-		mysqlUser,
-		mysqlPassword,
-		mysqlDatabase,
-		uint32(pid),
-		sc,
-		tables...,
-	)
-	if err != nil {
+	s := &server{
+		log:     log,
+		sc:      sc,
+		reg:     reg,
+		id:      uint32(pid),
+		lease:   lease,
+		origins: make(map[string]*origin),
+		errs:    make(chan originErr),
+	}
+
+	if err := s.sync(nil); err != nil {
 		log.Fatalf("failed to create mysql handler: %v", err)
 	}
 
-	if err := h.Handle(); err != nil {
-		log.Fatalf("failed to publish to tables %s: %v", mysqlTables, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := microdb.WatchFile(ctx, dataOriginPath, reloadInterval)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs,
+		os.Interrupt,
+		syscall.SIGHUP,
+		syscall.SIGINT,
+		syscall.SIGTERM,
+		syscall.SIGQUIT)
+
+	// SIGHUP reloads the data origin config file, other signals shut down the publisher.
+	for running := true; running; {
+		select {
+		case <-changes:
+			s.reload(dataOriginPath)
+
+		case sig := <-sigs:
+			if sig == syscall.SIGHUP {
+				s.reload(dataOriginPath)
+			} else {
+				running = false
+			}
+
+		case oe := <-s.errs:
+			s.stopped(oe)
+		}
 	}
-	if err := h.Close(); err != nil {
+
+	for k := range s.origins {
+		if err := s.stop(k); err != nil {
+			log.Printf("failed to stop publisher: %v", err)
+		}
+	}
+	if err := sc.Close(); err != nil {
 		log.Fatalf("failed to close connections: %v", err)
 	}
 }
 
-func parseTablesString(mysqlTables string) []string {
-	tables := []string{}
-	for _, t := range strings.Split(mysqlTables, ",") {
-		tables = append(tables, strings.TrimSpace(t))
+// sync starts, restarts and stops publishers to match the registry. Publishers of updated tables
+// are restarted even if the set of tables of their data origin did not change.
+//
+// Restarted publishers resume from their checkpoint, unless a table was added to their data origin
+// and all its tables need to be dumped again.
+func (s *server) sync(updated []string) error {
+	restart := make(map[string]bool)
+	for _, t := range updated {
+		restart[t] = true
+	}
+
+	want := make(map[string]*microdb.OriginGroup)
+	for _, g := range s.reg.Groups() {
+		if g.Connection.OriginType != microdb.DataOriginTypeMySQL {
+			s.log.Printf("Skipping tables %s, unsupported data origin %s.", strings.Join(g.Tables, ","), g.Connection)
+			continue
+		}
+		want[g.Key()] = g
 	}
 
-	return tables
+	resume := make(map[string]mysql.Position)
+	for k, o := range s.origins {
+		g, ok := want[k]
+		if ok && !changed(o.tables, g.Tables, restart) {
+			continue
+		}
+
+		if err := s.stop(k); err != nil {
+			return err
+		}
+		if ok && !added(o.tables, g.Tables) {
+			resume[k] = o.h.Checkpoint()
+		}
+	}
+
+	for k, g := range want {
+		if _, ok := s.origins[k]; ok {
+			continue
+		}
+
+		if err := s.start(g, resume[k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func changed(before, after []string, restart map[string]bool) bool {
+	if strings.Join(before, ",") != strings.Join(after, ",") {
+		return true
+	}
+
+	for _, t := range after {
+		if restart[t] {
+			return true
+		}
+	}
+	return false
+}
+
+// added reports whether after has tables that before does not have.
+func added(before, after []string) bool {
+	had := make(map[string]bool)
+	for _, t := range before {
+		had[t] = true
+	}
+
+	for _, t := range after {
+		if !had[t] {
+			return true
+		}
+	}
+	return false
+}
+
+// start starts a publisher of the tables of a data origin, from pos if it is not empty.
+func (s *server) start(g *microdb.OriginGroup, pos mysql.Position) error {
+	id, err := s.serverID()
+	if err != nil {
+		return err
+	}
+	h, err := publisher.MySQLOriginHandler(g.Connection, id, s.sc, s.reg, s.lease, g.Tables...)
+	if err != nil {
+		return err
+	}
+	h.ResumeFrom(pos)
+
+	o := &origin{
+		id:     id,
+		tables: g.Tables,
+		h:      h,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(o.done)
+
+		// Handle only returns once the publisher is stopped, or if publishing failed.
+		err := h.Handle()
+		s.errs <- originErr{o: o, err: err}
+	}()
+	s.origins[g.Key()] = o
+
+	if pos.Name != "" {
+		s.log.Printf("Publisher for %s on %s is ready, resuming from %s.", strings.Join(g.Tables, ","), g.Connection, pos)
+	} else {
+		s.log.Printf("Publisher for %s on %s is ready.", strings.Join(g.Tables, ","), g.Connection)
+	}
+	return nil
+}
+
+// serverID returns an unused replication server ID of the range of the publisher ID, so that
+// publishers with distinct IDs never use the same server ID.
+func (s *server) serverID() (uint32, error) {
+	used := make(map[uint32]bool)
+	for _, o := range s.origins {
+		used[o.id] = true
+	}
+
+	first := s.id * serverIDsPerPublisher
+	for id := first; id < first+serverIDsPerPublisher; id++ {
+		if !used[id] {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("more than %d data origins", serverIDsPerPublisher)
+}
+
+func (s *server) stop(key string) error {
+	o, ok := s.origins[key]
+	if !ok {
+		return nil
+	}
+	delete(s.origins, key)
+
+	if err := o.h.Stop(); err != nil {
+		return err
+	}
+
+	// Wait for the handler to return, it may still be publishing the last row update.
+	for {
+		select {
+		case <-o.done:
+			s.log.Printf("Publisher for %s is stopped.", strings.Join(o.tables, ","))
+			return nil
+		case oe := <-s.errs:
+			if oe.o != o {
+				s.stopped(oe)
+			}
+		}
+	}
+}
+
+// stopped handles a publisher that stopped on its own. Publishing failures are fatal, while
+// publishers that stopped cleanly are started again with the next config change.
+func (s *server) stopped(oe originErr) {
+	if oe.err != nil {
+		s.log.Fatalf("failed to publish to tables %s: %v", strings.Join(oe.o.tables, ","), oe.err)
+	}
+
+	for k, o := range s.origins {
+		if o == oe.o {
+			delete(s.origins, k)
+		}
+	}
+	s.log.Printf("Publisher for %s stopped.", strings.Join(oe.o.tables, ","))
+}
+
+// reload applies the changes of the data origin config file.
+func (s *server) reload(name string) {
+	change, err := s.reg.ReloadCfg(name)
+	if err != nil {
+		s.log.Printf("failed to reload data origin configs, keeping the current ones: %v", err)
+		return
+	}
+	if change.Empty() {
+		return
+	}
+
+	if err := s.sync(change.Updated); err != nil {
+		s.log.Fatalf("failed to apply data origin configs: %v", err)
+	}
 }
@@ -2,27 +2,102 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
+
+	"github.com/nats-io/stan.go"
+	"gopkg.in/yaml.v3"
 
 	"github.com/hojulian/microdb/internal/logger"
 	"github.com/hojulian/microdb/microdb"
 	"github.com/hojulian/microdb/querier"
 )
 
+// reloadInterval is how often the data origin config file is checked for changes.
+const reloadInterval = 5 * time.Second
+
+// server handles queries for every table in the data origin config, with one querier per data
+// origin connection.
+type server struct {
+	log *log.Logger
+	sc  stan.Conn
+	reg *microdb.Registry
+	// opts are the options of every querier.
+	opts []querier.Option
+
+	// origins maps a data origin connection to its querier.
+	origins map[string]querier.Handler
+	// tables maps a table to the data origin connection it is handled with.
+	tables map[string]string
+}
+
 func main() {
 	log := logger.Logger("querier")
 
-	mysqlHost := os.Getenv("MYSQL_HOST")
-	mysqlPort := os.Getenv("MYSQL_PORT")
-	mysqlUser := os.Getenv("MYSQL_USER")
-	mysqlPassword := os.Getenv("MYSQL_PASSWORD")
-	mysqlDatabase := os.Getenv("MYSQL_DATABASE")
-	mysqlTable := os.Getenv("MYSQL_TABLE")
-	dataOriginPath := os.Getenv("DATAORIGIN_CFG")
+	var (
+		dataOriginPath   = os.Getenv("DATAORIGIN_CFG")
+		requestCacheSize = os.Getenv("QUERIER_REQUEST_CACHE_SIZE")
+		requestTTL       = os.Getenv("QUERIER_REQUEST_TTL")
+		persistRequests  = os.Getenv("QUERIER_PERSIST_REQUESTS")
+		workers          = os.Getenv("QUERIER_WORKERS")
+		queueSize        = os.Getenv("QUERIER_QUEUE_SIZE")
+		clientKeys       = os.Getenv("QUERIER_CLIENT_KEYS")
+	)
 
-	if err := microdb.AddDataOriginFromCfg(dataOriginPath); err != nil {
+	size := querier.DefaultRequestCacheSize
+	if requestCacheSize != "" {
+		n, err := strconv.Atoi(requestCacheSize)
+		if err != nil {
+			log.Fatalf("request cache size must be an integer")
+		}
+		size = n
+	}
+	ttl := querier.DefaultRequestTTL
+	if requestTTL != "" {
+		d, err := time.ParseDuration(requestTTL)
+		if err != nil {
+			log.Fatalf("invalid request TTL: %v", err)
+		}
+		ttl = d
+	}
+	nw, nq := querier.DefaultWorkers, querier.DefaultQueueSize
+	if workers != "" {
+		n, err := strconv.Atoi(workers)
+		if err != nil {
+			log.Fatalf("number of workers must be an integer")
+		}
+		nw = n
+	}
+	if queueSize != "" {
+		n, err := strconv.Atoi(queueSize)
+		if err != nil {
+			log.Fatalf("queue size must be an integer")
+		}
+		nq = n
+	}
+
+	opts := []querier.Option{querier.WithRequestCache(size, ttl), querier.WithWorkers(nw, nq)}
+	if persistRequests == "true" {
+		opts = append(opts, querier.WithPersistedRequests())
+	}
+	if clientKeys != "" {
+		keys, err := readClientKeys(clientKeys)
+		if err != nil {
+			log.Fatalf("failed to read client keys: %v", err)
+		}
+		opts = append(opts, querier.WithClientKeys(keys))
+	}
+
+	reg := microdb.DefaultRegistry()
+	if err := reg.AddDataOriginFromCfg(dataOriginPath); err != nil {
 		log.Fatalf("failed to parse data origin configs: %v", err)
 	}
 
@@ -31,36 +106,180 @@ func main() {
 		log.Fatalf("failed to create nats connection: %v", err)
 	}
 
-	q, err := querier.MySQLHandler(
-		mysqlHost,
-		mysqlPort,
-		mysqlUser,
-		mysqlPassword,
-		mysqlDatabase,
-		mysqlTable,
-		sc,
-	)
-	if err != nil {
-		log.Fatalf("failed to create mysql querier: %v", err)
+	s := &server{
+		log:     log,
+		sc:      sc,
+		reg:     reg,
+		opts:    opts,
+		origins: make(map[string]querier.Handler),
+		tables:  make(map[string]string),
 	}
 
-	if err := q.Handle(); err != nil {
-		log.Fatalf("failed to publish to table %s: %v", mysqlTable, err)
+	if err := s.sync(nil); err != nil {
+		log.Fatalf("failed to start queriers: %v", err)
 	}
 
-	log.Printf("Querier for %s is ready.", mysqlTable)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := microdb.WatchFile(ctx, dataOriginPath, reloadInterval)
 
-	s := make(chan os.Signal, 1)
-	signal.Notify(s,
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs,
 		os.Interrupt,
 		syscall.SIGHUP,
 		syscall.SIGINT,
 		syscall.SIGTERM,
 		syscall.SIGQUIT)
 
-	<-s
+	// SIGHUP reloads the data origin config file, other signals shut down the querier.
+	for running := true; running; {
+		select {
+		case <-changes:
+			s.reload(dataOriginPath)
+
+		case sig := <-sigs:
+			if sig == syscall.SIGHUP {
+				s.reload(dataOriginPath)
+			} else {
+				running = false
+			}
+		}
+	}
+
+	for o, q := range s.origins {
+		if err := q.Stop(); err != nil {
+			log.Printf("failed to stop querier for %s: %v", o, err)
+		}
+	}
 
-	if err := q.Close(); err != nil {
+	if err := sc.Close(); err != nil {
 		log.Fatalf("failed to close connections: %v", err)
 	}
 }
+
+// sync starts and stops handling tables to match the registry. Updated tables are handled again
+// even if their data origin connection did not change.
+//
+// In-flight requests of removed or updated tables are completed before they are stopped.
+func (s *server) sync(updated []string) error {
+	want := make(map[string]*microdb.OriginGroup)
+	for _, g := range s.reg.Groups() {
+		if g.Connection.OriginType != microdb.DataOriginTypeMySQL {
+			s.log.Printf("Skipping tables %s, unsupported data origin %s.", strings.Join(g.Tables, ","), g.Connection)
+			continue
+		}
+
+		for _, t := range g.Tables {
+			want[t] = g
+		}
+	}
+
+	restart := make(map[string]bool)
+	for _, t := range updated {
+		restart[t] = true
+	}
+
+	for t, o := range s.tables {
+		if g, ok := want[t]; ok && g.Key() == o && !restart[t] {
+			continue
+		}
+
+		if err := s.origins[o].RemoveTable(t); err != nil {
+			s.log.Printf("failed to stop handling table %s: %v", t, err)
+		}
+		delete(s.tables, t)
+		s.log.Printf("Querier for %s is stopped.", t)
+	}
+
+	var errs []error
+	for t, g := range want {
+		if _, ok := s.tables[t]; ok {
+			continue
+		}
+
+		if err := s.add(g, t); err != nil {
+			errs = append(errs, err)
+			s.log.Printf("failed to start handling table %s: %v", t, err)
+			continue
+		}
+		s.log.Printf("Querier for %s on %s is ready.", t, g.Connection)
+	}
+
+	for o, q := range s.origins {
+		if len(q.Tables()) > 0 {
+			continue
+		}
+
+		if err := q.Stop(); err != nil {
+			s.log.Printf("failed to stop querier: %v", err)
+		}
+		delete(s.origins, o)
+	}
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+func (s *server) add(g *microdb.OriginGroup, table string) error {
+	q, ok := s.origins[g.Key()]
+	if !ok {
+		nq, err := querier.MySQLOriginHandler(g.Connection, s.sc, s.reg, nil, s.opts...)
+		if err != nil {
+			return err
+		}
+		if err := nq.Handle(); err != nil {
+			return err
+		}
+
+		q = nq
+		s.origins[g.Key()] = q
+	}
+
+	if err := q.AddTable(table); err != nil {
+		return err
+	}
+	s.tables[table] = g.Key()
+
+	return nil
+}
+
+// reload applies the changes of the data origin config file.
+func (s *server) reload(name string) {
+	change, err := s.reg.ReloadCfg(name)
+	if err != nil {
+		s.log.Printf("failed to reload data origin configs, keeping the current ones: %v", err)
+		return
+	}
+	if change.Empty() {
+		return
+	}
+
+	if err := s.sync(change.Updated); err != nil {
+		s.log.Printf("failed to apply data origin configs: %v", err)
+	}
+}
+
+// readClientKeys reads a YAML file mapping client IDs to their keys.
+func readClientKeys(name string) (map[string][]byte, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var m map[string]string
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse file: %w", err)
+	}
+
+	keys := make(map[string][]byte, len(m))
+	for id, k := range m {
+		if k == "" {
+			return nil, fmt.Errorf("empty key for client %q", id)
+		}
+		keys[id] = []byte(k)
+	}
+
+	return keys, nil
+}
@@ -14,7 +14,7 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.7
 	github.com/moby/term v0.0.0-20201216013528-df9cb8a40635 // indirect
 	github.com/nats-io/nats-server/v2 v2.2.1 // indirect
-	github.com/nats-io/nats-streaming-server v0.21.1 // indirect
+	github.com/nats-io/nats-streaming-server v0.21.1
 	github.com/nats-io/nats.go v1.10.1-0.20210330225420-a0b1f60162f8
 	github.com/nats-io/stan.go v0.8.3
 	github.com/opencontainers/runc v1.0.0-rc93 // indirect
@@ -0,0 +1,66 @@
+package proto //nolint // Package comment located in a different file.
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Request signatures
+
+// Sign signs a request as the client id with its key.
+func (x *QueryRequest) Sign(id string, key []byte, now time.Time) error {
+	x.Auth = &Auth{ClientId: id, SignedAt: timestamppb.New(now)}
+
+	sig, err := x.signature(key)
+	if err != nil {
+		return err
+	}
+	x.Auth.Signature = sig
+
+	return nil
+}
+
+// Verify checks the signature of a signed request with the key of its client, and that it was signed
+// within maxSkew of now.
+func (x *QueryRequest) Verify(key []byte, now time.Time, maxSkew time.Duration) error {
+	a := x.GetAuth()
+	if a == nil {
+		return errors.New("request is not signed")
+	}
+
+	if d := now.Sub(a.GetSignedAt().AsTime()); d > maxSkew || d < -maxSkew {
+		return fmt.Errorf("request signed %s away from now", d)
+	}
+
+	sig := a.Signature
+	a.Signature = nil
+	exp, err := x.signature(key)
+	a.Signature = sig
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal(sig, exp) {
+		return errors.New("invalid request signature")
+	}
+
+	return nil
+}
+
+func (x *QueryRequest) signature(key []byte) ([]byte, error) {
+	p, err := proto.MarshalOptions{Deterministic: true}.Marshal(x)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(p) //nolint // Writing to a hash never fails.
+
+	return mac.Sum(nil), nil
+}
@@ -0,0 +1,78 @@
+package proto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	key := []byte("secret")
+
+	testCases := []struct {
+		desc   string
+		modify func(*QueryRequest)
+		key    []byte
+		now    time.Time
+		ok     bool
+	}{
+		{
+			desc: "valid signature",
+			key:  key,
+			now:  now,
+			ok:   true,
+		},
+		{
+			desc: "wrong key",
+			key:  []byte("other"),
+			now:  now,
+		},
+		{
+			desc:   "modified query",
+			modify: func(r *QueryRequest) { r.Query = "DELETE FROM test" },
+			key:    key,
+			now:    now,
+		},
+		{
+			desc:   "modified client",
+			modify: func(r *QueryRequest) { r.Auth.ClientId = "admin" },
+			key:    key,
+			now:    now,
+		},
+		{
+			desc: "expired signature",
+			key:  key,
+			now:  now.Add(time.Hour),
+		},
+		{
+			desc:   "unsigned",
+			modify: func(r *QueryRequest) { r.Auth = nil },
+			key:    key,
+			now:    now,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			req := &QueryRequest{
+				Query:     "INSERT INTO test (id) VALUES (?)",
+				Args:      MarshalValues([]interface{}{1}),
+				RequestId: "request",
+			}
+			assert.Nil(t, req.Sign("orders", key, now))
+
+			if tC.modify != nil {
+				tC.modify(req)
+			}
+
+			err := req.Verify(tC.key, tC.now, time.Minute)
+			if tC.ok {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+			}
+		})
+	}
+}
@@ -26,6 +26,146 @@ const (
 // of the legacy proto package is being used.
 const _ = proto.ProtoPackageIsVersion4
 
+type ErrorCode int32
+
+const (
+	ErrorCode_ERROR_CODE_UNKNOWN ErrorCode = 0
+	// The request is malformed.
+	ErrorCode_ERROR_CODE_INVALID_REQUEST ErrorCode = 1
+	// The query could not be parsed, or refers to unknown tables or columns.
+	ErrorCode_ERROR_CODE_SYNTAX        ErrorCode = 2
+	ErrorCode_ERROR_CODE_DUPLICATE_KEY ErrorCode = 3
+	// Any other integrity constraint, e.g. foreign keys or non-null columns.
+	ErrorCode_ERROR_CODE_CONSTRAINT_VIOLATION ErrorCode = 4
+	ErrorCode_ERROR_CODE_DEADLOCK             ErrorCode = 5
+	ErrorCode_ERROR_CODE_LOCK_WAIT_TIMEOUT    ErrorCode = 6
+	// The data origin could not be reached.
+	ErrorCode_ERROR_CODE_UNAVAILABLE ErrorCode = 7
+	// The querier is handling too many requests.
+	ErrorCode_ERROR_CODE_OVERLOADED ErrorCode = 8
+	// The request deadline passed before it completed.
+	ErrorCode_ERROR_CODE_DEADLINE_EXCEEDED ErrorCode = 9
+	ErrorCode_ERROR_CODE_INTERNAL          ErrorCode = 10
+	// The request is not signed, or its signature is invalid.
+	ErrorCode_ERROR_CODE_UNAUTHENTICATED ErrorCode = 11
+	// The client is not allowed to execute the query.
+	ErrorCode_ERROR_CODE_PERMISSION_DENIED ErrorCode = 12
+)
+
+// Enum value maps for ErrorCode.
+var (
+	ErrorCode_name = map[int32]string{
+		0:  "ERROR_CODE_UNKNOWN",
+		1:  "ERROR_CODE_INVALID_REQUEST",
+		2:  "ERROR_CODE_SYNTAX",
+		3:  "ERROR_CODE_DUPLICATE_KEY",
+		4:  "ERROR_CODE_CONSTRAINT_VIOLATION",
+		5:  "ERROR_CODE_DEADLOCK",
+		6:  "ERROR_CODE_LOCK_WAIT_TIMEOUT",
+		7:  "ERROR_CODE_UNAVAILABLE",
+		8:  "ERROR_CODE_OVERLOADED",
+		9:  "ERROR_CODE_DEADLINE_EXCEEDED",
+		10: "ERROR_CODE_INTERNAL",
+		11: "ERROR_CODE_UNAUTHENTICATED",
+		12: "ERROR_CODE_PERMISSION_DENIED",
+	}
+	ErrorCode_value = map[string]int32{
+		"ERROR_CODE_UNKNOWN":              0,
+		"ERROR_CODE_INVALID_REQUEST":      1,
+		"ERROR_CODE_SYNTAX":               2,
+		"ERROR_CODE_DUPLICATE_KEY":        3,
+		"ERROR_CODE_CONSTRAINT_VIOLATION": 4,
+		"ERROR_CODE_DEADLOCK":             5,
+		"ERROR_CODE_LOCK_WAIT_TIMEOUT":    6,
+		"ERROR_CODE_UNAVAILABLE":          7,
+		"ERROR_CODE_OVERLOADED":           8,
+		"ERROR_CODE_DEADLINE_EXCEEDED":    9,
+		"ERROR_CODE_INTERNAL":             10,
+		"ERROR_CODE_UNAUTHENTICATED":      11,
+		"ERROR_CODE_PERMISSION_DENIED":    12,
+	}
+)
+
+func (x ErrorCode) Enum() *ErrorCode {
+	p := new(ErrorCode)
+	*p = x
+	return p
+}
+
+func (x ErrorCode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ErrorCode) Descriptor() protoreflect.EnumDescriptor {
+	return file_microdb_proto_enumTypes[0].Descriptor()
+}
+
+func (ErrorCode) Type() protoreflect.EnumType {
+	return &file_microdb_proto_enumTypes[0]
+}
+
+func (x ErrorCode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ErrorCode.Descriptor instead.
+func (ErrorCode) EnumDescriptor() ([]byte, []int) {
+	return file_microdb_proto_rawDescGZIP(), []int{0}
+}
+
+type RowOp int32
+
+const (
+	// Row updates of publishers predating ops, applied as an upsert of row.
+	RowOp_ROW_OP_UNSPECIFIED RowOp = 0
+	RowOp_ROW_OP_INSERT      RowOp = 1
+	RowOp_ROW_OP_UPDATE      RowOp = 2
+	RowOp_ROW_OP_DELETE      RowOp = 3
+)
+
+// Enum value maps for RowOp.
+var (
+	RowOp_name = map[int32]string{
+		0: "ROW_OP_UNSPECIFIED",
+		1: "ROW_OP_INSERT",
+		2: "ROW_OP_UPDATE",
+		3: "ROW_OP_DELETE",
+	}
+	RowOp_value = map[string]int32{
+		"ROW_OP_UNSPECIFIED": 0,
+		"ROW_OP_INSERT":      1,
+		"ROW_OP_UPDATE":      2,
+		"ROW_OP_DELETE":      3,
+	}
+)
+
+func (x RowOp) Enum() *RowOp {
+	p := new(RowOp)
+	*p = x
+	return p
+}
+
+func (x RowOp) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RowOp) Descriptor() protoreflect.EnumDescriptor {
+	return file_microdb_proto_enumTypes[1].Descriptor()
+}
+
+func (RowOp) Type() protoreflect.EnumType {
+	return &file_microdb_proto_enumTypes[1]
+}
+
+func (x RowOp) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RowOp.Descriptor instead.
+func (RowOp) EnumDescriptor() ([]byte, []int) {
+	return file_microdb_proto_rawDescGZIP(), []int{1}
+}
+
 type Value struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -207,6 +347,17 @@ type QueryRequest struct {
 
 	Query string   `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
 	Args  []*Value `protobuf:"bytes,2,rep,name=args,proto3" json:"args,omitempty"`
+	// Identifies the request across retries, so that the querier executes it only once.
+	RequestId string `protobuf:"bytes,3,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	// Statements of a batch, executed in a single transaction instead of query and args.
+	Statements []*Statement `protobuf:"bytes,4,rep,name=statements,proto3" json:"statements,omitempty"`
+	// The request is abandoned if it is not completed by the deadline.
+	Deadline *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=deadline,proto3" json:"deadline,omitempty"`
+	// Identifies the client that sent the request, see Auth.
+	Auth *Auth `protobuf:"bytes,6,opt,name=auth,proto3" json:"auth,omitempty"`
+	// Executes the named statement of the table instead of query, with args.
+	Table     string `protobuf:"bytes,7,opt,name=table,proto3" json:"table,omitempty"`
+	Statement string `protobuf:"bytes,8,opt,name=statement,proto3" json:"statement,omitempty"`
 }
 
 func (x *QueryRequest) Reset() {
@@ -255,6 +406,168 @@ func (x *QueryRequest) GetArgs() []*Value {
 	return nil
 }
 
+func (x *QueryRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetStatements() []*Statement {
+	if x != nil {
+		return x.Statements
+	}
+	return nil
+}
+
+func (x *QueryRequest) GetDeadline() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Deadline
+	}
+	return nil
+}
+
+func (x *QueryRequest) GetAuth() *Auth {
+	if x != nil {
+		return x.Auth
+	}
+	return nil
+}
+
+func (x *QueryRequest) GetTable() string {
+	if x != nil {
+		return x.Table
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetStatement() string {
+	if x != nil {
+		return x.Statement
+	}
+	return ""
+}
+
+// Auth authenticates the client that sent a request. The signature is the HMAC-SHA256, with the
+// key of the client, of the deterministically marshaled request without the signature.
+type Auth struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ClientId  string                 `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	SignedAt  *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=signed_at,json=signedAt,proto3" json:"signed_at,omitempty"`
+	Signature []byte                 `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (x *Auth) Reset() {
+	*x = Auth{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_microdb_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Auth) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Auth) ProtoMessage() {}
+
+func (x *Auth) ProtoReflect() protoreflect.Message {
+	mi := &file_microdb_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Auth.ProtoReflect.Descriptor instead.
+func (*Auth) Descriptor() ([]byte, []int) {
+	return file_microdb_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Auth) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *Auth) GetSignedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.SignedAt
+	}
+	return nil
+}
+
+func (x *Auth) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+type Statement struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Query string   `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	Args  []*Value `protobuf:"bytes,2,rep,name=args,proto3" json:"args,omitempty"`
+}
+
+func (x *Statement) Reset() {
+	*x = Statement{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_microdb_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Statement) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Statement) ProtoMessage() {}
+
+func (x *Statement) ProtoReflect() protoreflect.Message {
+	mi := &file_microdb_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Statement.ProtoReflect.Descriptor instead.
+func (*Statement) Descriptor() ([]byte, []int) {
+	return file_microdb_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Statement) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *Statement) GetArgs() []*Value {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
 type WriteQueryReply struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -263,12 +576,21 @@ type WriteQueryReply struct {
 	Ok     bool          `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
 	Msg    string        `protobuf:"bytes,2,opt,name=msg,proto3" json:"msg,omitempty"`
 	Result *DriverResult `protobuf:"bytes,3,opt,name=result,proto3" json:"result,omitempty"`
+	// Set when ok is false.
+	Code ErrorCode `protobuf:"varint,4,opt,name=code,proto3,enum=proto.ErrorCode" json:"code,omitempty"`
+	// Error number and SQLSTATE of the data origin, if the error comes from it.
+	OriginErrno uint32 `protobuf:"varint,5,opt,name=origin_errno,json=originErrno,proto3" json:"origin_errno,omitempty"`
+	Sqlstate    string `protobuf:"bytes,6,opt,name=sqlstate,proto3" json:"sqlstate,omitempty"`
+	// Results of each statement of a batch.
+	Results []*DriverResult `protobuf:"bytes,7,rep,name=results,proto3" json:"results,omitempty"`
+	// Index of the statement of a batch that failed, or -1.
+	FailedStatement int32 `protobuf:"varint,8,opt,name=failed_statement,json=failedStatement,proto3" json:"failed_statement,omitempty"`
 }
 
 func (x *WriteQueryReply) Reset() {
 	*x = WriteQueryReply{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_microdb_proto_msgTypes[3]
+		mi := &file_microdb_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -281,7 +603,7 @@ func (x *WriteQueryReply) String() string {
 func (*WriteQueryReply) ProtoMessage() {}
 
 func (x *WriteQueryReply) ProtoReflect() protoreflect.Message {
-	mi := &file_microdb_proto_msgTypes[3]
+	mi := &file_microdb_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -294,7 +616,7 @@ func (x *WriteQueryReply) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use WriteQueryReply.ProtoReflect.Descriptor instead.
 func (*WriteQueryReply) Descriptor() ([]byte, []int) {
-	return file_microdb_proto_rawDescGZIP(), []int{3}
+	return file_microdb_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *WriteQueryReply) GetOk() bool {
@@ -318,6 +640,41 @@ func (x *WriteQueryReply) GetResult() *DriverResult {
 	return nil
 }
 
+func (x *WriteQueryReply) GetCode() ErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return ErrorCode_ERROR_CODE_UNKNOWN
+}
+
+func (x *WriteQueryReply) GetOriginErrno() uint32 {
+	if x != nil {
+		return x.OriginErrno
+	}
+	return 0
+}
+
+func (x *WriteQueryReply) GetSqlstate() string {
+	if x != nil {
+		return x.Sqlstate
+	}
+	return ""
+}
+
+func (x *WriteQueryReply) GetResults() []*DriverResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+func (x *WriteQueryReply) GetFailedStatement() int32 {
+	if x != nil {
+		return x.FailedStatement
+	}
+	return 0
+}
+
 type DriverResult struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -330,7 +687,7 @@ type DriverResult struct {
 func (x *DriverResult) Reset() {
 	*x = DriverResult{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_microdb_proto_msgTypes[4]
+		mi := &file_microdb_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -343,7 +700,7 @@ func (x *DriverResult) String() string {
 func (*DriverResult) ProtoMessage() {}
 
 func (x *DriverResult) ProtoReflect() protoreflect.Message {
-	mi := &file_microdb_proto_msgTypes[4]
+	mi := &file_microdb_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -356,7 +713,7 @@ func (x *DriverResult) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DriverResult.ProtoReflect.Descriptor instead.
 func (*DriverResult) Descriptor() ([]byte, []int) {
-	return file_microdb_proto_rawDescGZIP(), []int{4}
+	return file_microdb_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *DriverResult) GetResultLastInsertId() int64 {
@@ -378,13 +735,19 @@ type RowUpdate struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
+	// The row after the change, or the deleted row.
 	Row []*Value `protobuf:"bytes,1,rep,name=row,proto3" json:"row,omitempty"`
+	Op  RowOp    `protobuf:"varint,2,opt,name=op,proto3,enum=proto.RowOp" json:"op,omitempty"`
+	// The row before an update.
+	OldRow []*Value `protobuf:"bytes,3,rep,name=old_row,json=oldRow,proto3" json:"old_row,omitempty"`
+	// When the change was committed to the data origin, if known.
+	CommittedAt *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=committed_at,json=committedAt,proto3" json:"committed_at,omitempty"`
 }
 
 func (x *RowUpdate) Reset() {
 	*x = RowUpdate{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_microdb_proto_msgTypes[5]
+		mi := &file_microdb_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -397,7 +760,7 @@ func (x *RowUpdate) String() string {
 func (*RowUpdate) ProtoMessage() {}
 
 func (x *RowUpdate) ProtoReflect() protoreflect.Message {
-	mi := &file_microdb_proto_msgTypes[5]
+	mi := &file_microdb_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -410,7 +773,7 @@ func (x *RowUpdate) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RowUpdate.ProtoReflect.Descriptor instead.
 func (*RowUpdate) Descriptor() ([]byte, []int) {
-	return file_microdb_proto_rawDescGZIP(), []int{5}
+	return file_microdb_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *RowUpdate) GetRow() []*Value {
@@ -420,6 +783,118 @@ func (x *RowUpdate) GetRow() []*Value {
 	return nil
 }
 
+func (x *RowUpdate) GetOp() RowOp {
+	if x != nil {
+		return x.Op
+	}
+	return RowOp_ROW_OP_UNSPECIFIED
+}
+
+func (x *RowUpdate) GetOldRow() []*Value {
+	if x != nil {
+		return x.OldRow
+	}
+	return nil
+}
+
+func (x *RowUpdate) GetCommittedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CommittedAt
+	}
+	return nil
+}
+
+// A row update that a client failed to apply to its local table.
+type DeadLetter struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Table string `protobuf:"bytes,1,opt,name=table,proto3" json:"table,omitempty"`
+	// The original row update message.
+	Data  []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Error string `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	// Sequence number of the row update in the table topic.
+	Sequence uint64                 `protobuf:"varint,4,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	FailedAt *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=failed_at,json=failedAt,proto3" json:"failed_at,omitempty"`
+	// NATS client ID of the client that failed to apply the row update.
+	ClientId string `protobuf:"bytes,6,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+}
+
+func (x *DeadLetter) Reset() {
+	*x = DeadLetter{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_microdb_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeadLetter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeadLetter) ProtoMessage() {}
+
+func (x *DeadLetter) ProtoReflect() protoreflect.Message {
+	mi := &file_microdb_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeadLetter.ProtoReflect.Descriptor instead.
+func (*DeadLetter) Descriptor() ([]byte, []int) {
+	return file_microdb_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DeadLetter) GetTable() string {
+	if x != nil {
+		return x.Table
+	}
+	return ""
+}
+
+func (x *DeadLetter) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *DeadLetter) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *DeadLetter) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *DeadLetter) GetFailedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FailedAt
+	}
+	return nil
+}
+
+func (x *DeadLetter) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
 var File_microdb_proto protoreflect.FileDescriptor
 
 var file_microdb_proto_rawDesc = []byte{
@@ -442,27 +917,116 @@ var file_microdb_proto_rawDesc = []byte{
 	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x48, 0x00, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65,
 	0x73, 0x74, 0x61, 0x6d, 0x70, 0x42, 0x0d, 0x0a, 0x0b, 0x74, 0x79, 0x70, 0x65, 0x64, 0x5f, 0x76,
 	0x61, 0x6c, 0x75, 0x65, 0x22, 0x0b, 0x0a, 0x09, 0x4e, 0x75, 0x6c, 0x6c, 0x56, 0x61, 0x6c, 0x75,
-	0x65, 0x22, 0x46, 0x0a, 0x0c, 0x51, 0x75, 0x65, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x65, 0x22, 0xa4, 0x02, 0x0a, 0x0c, 0x51, 0x75, 0x65, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x12, 0x20, 0x0a, 0x04, 0x61, 0x72, 0x67, 0x73,
+	0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x56,
+	0x61, 0x6c, 0x75, 0x65, 0x52, 0x04, 0x61, 0x72, 0x67, 0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x72, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09,
+	0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x49, 0x64, 0x12, 0x30, 0x0a, 0x0a, 0x73, 0x74, 0x61,
+	0x74, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x52,
+	0x0a, 0x73, 0x74, 0x61, 0x74, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x36, 0x0a, 0x08, 0x64,
+	0x65, 0x61, 0x64, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x08, 0x64, 0x65, 0x61, 0x64, 0x6c,
+	0x69, 0x6e, 0x65, 0x12, 0x1f, 0x0a, 0x04, 0x61, 0x75, 0x74, 0x68, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x0b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x52, 0x04,
+	0x61, 0x75, 0x74, 0x68, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x74,
+	0x61, 0x74, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x73,
+	0x74, 0x61, 0x74, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x22, 0x7a, 0x0a, 0x04, 0x41, 0x75, 0x74, 0x68,
+	0x12, 0x1b, 0x0a, 0x09, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x12, 0x37, 0x0a,
+	0x09, 0x73, 0x69, 0x67, 0x6e, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
+	0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x08, 0x73, 0x69,
+	0x67, 0x6e, 0x65, 0x64, 0x41, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74,
+	0x75, 0x72, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61,
+	0x74, 0x75, 0x72, 0x65, 0x22, 0x43, 0x0a, 0x09, 0x53, 0x74, 0x61, 0x74, 0x65, 0x6d, 0x65, 0x6e,
 	0x74, 0x12, 0x14, 0x0a, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
 	0x52, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x12, 0x20, 0x0a, 0x04, 0x61, 0x72, 0x67, 0x73, 0x18,
 	0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x56, 0x61,
-	0x6c, 0x75, 0x65, 0x52, 0x04, 0x61, 0x72, 0x67, 0x73, 0x22, 0x60, 0x0a, 0x0f, 0x57, 0x72, 0x69,
-	0x74, 0x65, 0x51, 0x75, 0x65, 0x72, 0x79, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x0e, 0x0a, 0x02,
-	0x6f, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x02, 0x6f, 0x6b, 0x12, 0x10, 0x0a, 0x03,
-	0x6d, 0x73, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6d, 0x73, 0x67, 0x12, 0x2b,
-	0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13,
-	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x44, 0x72, 0x69, 0x76, 0x65, 0x72, 0x52, 0x65, 0x73,
-	0x75, 0x6c, 0x74, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x22, 0x6e, 0x0a, 0x0c, 0x44,
+	0x6c, 0x75, 0x65, 0x52, 0x04, 0x61, 0x72, 0x67, 0x73, 0x22, 0x9f, 0x02, 0x0a, 0x0f, 0x57, 0x72,
+	0x69, 0x74, 0x65, 0x51, 0x75, 0x65, 0x72, 0x79, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x0e, 0x0a,
+	0x02, 0x6f, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x02, 0x6f, 0x6b, 0x12, 0x10, 0x0a,
+	0x03, 0x6d, 0x73, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6d, 0x73, 0x67, 0x12,
+	0x2b, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x13, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x44, 0x72, 0x69, 0x76, 0x65, 0x72, 0x52, 0x65,
+	0x73, 0x75, 0x6c, 0x74, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x24, 0x0a, 0x04,
+	0x63, 0x6f, 0x64, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x10, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x63, 0x6f,
+	0x64, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x5f, 0x65, 0x72, 0x72,
+	0x6e, 0x6f, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e,
+	0x45, 0x72, 0x72, 0x6e, 0x6f, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x71, 0x6c, 0x73, 0x74, 0x61, 0x74,
+	0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x71, 0x6c, 0x73, 0x74, 0x61, 0x74,
+	0x65, 0x12, 0x2d, 0x0a, 0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x18, 0x07, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x13, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x44, 0x72, 0x69, 0x76, 0x65,
+	0x72, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73,
+	0x12, 0x29, 0x0a, 0x10, 0x66, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x65,
+	0x6d, 0x65, 0x6e, 0x74, 0x18, 0x08, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0f, 0x66, 0x61, 0x69, 0x6c,
+	0x65, 0x64, 0x53, 0x74, 0x61, 0x74, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x22, 0x6e, 0x0a, 0x0c, 0x44,
 	0x72, 0x69, 0x76, 0x65, 0x72, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x2e, 0x0a, 0x12, 0x72,
 	0x65, 0x73, 0x75, 0x6c, 0x74, 0x4c, 0x61, 0x73, 0x74, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x49,
 	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x12, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x4c,
 	0x61, 0x73, 0x74, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x49, 0x64, 0x12, 0x2e, 0x0a, 0x12, 0x72,
 	0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x6f, 0x77, 0x73, 0x41, 0x66, 0x66, 0x65, 0x63, 0x74, 0x65,
 	0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x12, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52,
-	0x6f, 0x77, 0x73, 0x41, 0x66, 0x66, 0x65, 0x63, 0x74, 0x65, 0x64, 0x22, 0x2b, 0x0a, 0x09, 0x52,
-	0x6f, 0x77, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x12, 0x1e, 0x0a, 0x03, 0x72, 0x6f, 0x77, 0x18,
-	0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x56, 0x61,
-	0x6c, 0x75, 0x65, 0x52, 0x03, 0x72, 0x6f, 0x77, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x6f, 0x77, 0x73, 0x41, 0x66, 0x66, 0x65, 0x63, 0x74, 0x65, 0x64, 0x22, 0xaf, 0x01, 0x0a, 0x09,
+	0x52, 0x6f, 0x77, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x12, 0x1e, 0x0a, 0x03, 0x72, 0x6f, 0x77,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x56,
+	0x61, 0x6c, 0x75, 0x65, 0x52, 0x03, 0x72, 0x6f, 0x77, 0x12, 0x1c, 0x0a, 0x02, 0x6f, 0x70, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0c, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x52, 0x6f,
+	0x77, 0x4f, 0x70, 0x52, 0x02, 0x6f, 0x70, 0x12, 0x25, 0x0a, 0x07, 0x6f, 0x6c, 0x64, 0x5f, 0x72,
+	0x6f, 0x77, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x52, 0x06, 0x6f, 0x6c, 0x64, 0x52, 0x6f, 0x77, 0x12, 0x3d,
+	0x0a, 0x0c, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
+	0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x74, 0x65, 0x64, 0x41, 0x74, 0x22, 0xbe, 0x01,
+	0x0a, 0x0a, 0x44, 0x65, 0x61, 0x64, 0x4c, 0x65, 0x74, 0x74, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05,
+	0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x61, 0x62,
+	0x6c, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x1a, 0x0a, 0x08,
+	0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08,
+	0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x37, 0x0a, 0x09, 0x66, 0x61, 0x69, 0x6c,
+	0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x08, 0x66, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x41,
+	0x74, 0x12, 0x1b, 0x0a, 0x09, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x2a, 0x8c,
+	0x03, 0x0a, 0x09, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x16, 0x0a, 0x12,
+	0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f,
+	0x57, 0x4e, 0x10, 0x00, 0x12, 0x1e, 0x0a, 0x1a, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x43, 0x4f,
+	0x44, 0x45, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x5f, 0x52, 0x45, 0x51, 0x55, 0x45,
+	0x53, 0x54, 0x10, 0x01, 0x12, 0x15, 0x0a, 0x11, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x43, 0x4f,
+	0x44, 0x45, 0x5f, 0x53, 0x59, 0x4e, 0x54, 0x41, 0x58, 0x10, 0x02, 0x12, 0x1c, 0x0a, 0x18, 0x45,
+	0x52, 0x52, 0x4f, 0x52, 0x5f, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x44, 0x55, 0x50, 0x4c, 0x49, 0x43,
+	0x41, 0x54, 0x45, 0x5f, 0x4b, 0x45, 0x59, 0x10, 0x03, 0x12, 0x23, 0x0a, 0x1f, 0x45, 0x52, 0x52,
+	0x4f, 0x52, 0x5f, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x43, 0x4f, 0x4e, 0x53, 0x54, 0x52, 0x41, 0x49,
+	0x4e, 0x54, 0x5f, 0x56, 0x49, 0x4f, 0x4c, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x10, 0x04, 0x12, 0x17,
+	0x0a, 0x13, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x44, 0x45, 0x41,
+	0x44, 0x4c, 0x4f, 0x43, 0x4b, 0x10, 0x05, 0x12, 0x20, 0x0a, 0x1c, 0x45, 0x52, 0x52, 0x4f, 0x52,
+	0x5f, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x4c, 0x4f, 0x43, 0x4b, 0x5f, 0x57, 0x41, 0x49, 0x54, 0x5f,
+	0x54, 0x49, 0x4d, 0x45, 0x4f, 0x55, 0x54, 0x10, 0x06, 0x12, 0x1a, 0x0a, 0x16, 0x45, 0x52, 0x52,
+	0x4f, 0x52, 0x5f, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x55, 0x4e, 0x41, 0x56, 0x41, 0x49, 0x4c, 0x41,
+	0x42, 0x4c, 0x45, 0x10, 0x07, 0x12, 0x19, 0x0a, 0x15, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x43,
+	0x4f, 0x44, 0x45, 0x5f, 0x4f, 0x56, 0x45, 0x52, 0x4c, 0x4f, 0x41, 0x44, 0x45, 0x44, 0x10, 0x08,
+	0x12, 0x20, 0x0a, 0x1c, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x44,
+	0x45, 0x41, 0x44, 0x4c, 0x49, 0x4e, 0x45, 0x5f, 0x45, 0x58, 0x43, 0x45, 0x45, 0x44, 0x45, 0x44,
+	0x10, 0x09, 0x12, 0x17, 0x0a, 0x13, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x43, 0x4f, 0x44, 0x45,
+	0x5f, 0x49, 0x4e, 0x54, 0x45, 0x52, 0x4e, 0x41, 0x4c, 0x10, 0x0a, 0x12, 0x1e, 0x0a, 0x1a, 0x45,
+	0x52, 0x52, 0x4f, 0x52, 0x5f, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x55, 0x4e, 0x41, 0x55, 0x54, 0x48,
+	0x45, 0x4e, 0x54, 0x49, 0x43, 0x41, 0x54, 0x45, 0x44, 0x10, 0x0b, 0x12, 0x20, 0x0a, 0x1c, 0x45,
+	0x52, 0x52, 0x4f, 0x52, 0x5f, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x50, 0x45, 0x52, 0x4d, 0x49, 0x53,
+	0x53, 0x49, 0x4f, 0x4e, 0x5f, 0x44, 0x45, 0x4e, 0x49, 0x45, 0x44, 0x10, 0x0c, 0x2a, 0x58, 0x0a,
+	0x05, 0x52, 0x6f, 0x77, 0x4f, 0x70, 0x12, 0x16, 0x0a, 0x12, 0x52, 0x4f, 0x57, 0x5f, 0x4f, 0x50,
+	0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x11,
+	0x0a, 0x0d, 0x52, 0x4f, 0x57, 0x5f, 0x4f, 0x50, 0x5f, 0x49, 0x4e, 0x53, 0x45, 0x52, 0x54, 0x10,
+	0x01, 0x12, 0x11, 0x0a, 0x0d, 0x52, 0x4f, 0x57, 0x5f, 0x4f, 0x50, 0x5f, 0x55, 0x50, 0x44, 0x41,
+	0x54, 0x45, 0x10, 0x02, 0x12, 0x11, 0x0a, 0x0d, 0x52, 0x4f, 0x57, 0x5f, 0x4f, 0x50, 0x5f, 0x44,
+	0x45, 0x4c, 0x45, 0x54, 0x45, 0x10, 0x03, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -477,27 +1041,44 @@ func file_microdb_proto_rawDescGZIP() []byte {
 	return file_microdb_proto_rawDescData
 }
 
-var file_microdb_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_microdb_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_microdb_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
 var file_microdb_proto_goTypes = []interface{}{
-	(*Value)(nil),                 // 0: proto.Value
-	(*NullValue)(nil),             // 1: proto.NullValue
-	(*QueryRequest)(nil),          // 2: proto.QueryRequest
-	(*WriteQueryReply)(nil),       // 3: proto.WriteQueryReply
-	(*DriverResult)(nil),          // 4: proto.DriverResult
-	(*RowUpdate)(nil),             // 5: proto.RowUpdate
-	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+	(ErrorCode)(0),                // 0: proto.ErrorCode
+	(RowOp)(0),                    // 1: proto.RowOp
+	(*Value)(nil),                 // 2: proto.Value
+	(*NullValue)(nil),             // 3: proto.NullValue
+	(*QueryRequest)(nil),          // 4: proto.QueryRequest
+	(*Auth)(nil),                  // 5: proto.Auth
+	(*Statement)(nil),             // 6: proto.Statement
+	(*WriteQueryReply)(nil),       // 7: proto.WriteQueryReply
+	(*DriverResult)(nil),          // 8: proto.DriverResult
+	(*RowUpdate)(nil),             // 9: proto.RowUpdate
+	(*DeadLetter)(nil),            // 10: proto.DeadLetter
+	(*timestamppb.Timestamp)(nil), // 11: google.protobuf.Timestamp
 }
 var file_microdb_proto_depIdxs = []int32{
-	1, // 0: proto.Value.null:type_name -> proto.NullValue
-	6, // 1: proto.Value.timestamp:type_name -> google.protobuf.Timestamp
-	0, // 2: proto.QueryRequest.args:type_name -> proto.Value
-	4, // 3: proto.WriteQueryReply.result:type_name -> proto.DriverResult
-	0, // 4: proto.RowUpdate.row:type_name -> proto.Value
-	5, // [5:5] is the sub-list for method output_type
-	5, // [5:5] is the sub-list for method input_type
-	5, // [5:5] is the sub-list for extension type_name
-	5, // [5:5] is the sub-list for extension extendee
-	0, // [0:5] is the sub-list for field type_name
+	3,  // 0: proto.Value.null:type_name -> proto.NullValue
+	11, // 1: proto.Value.timestamp:type_name -> google.protobuf.Timestamp
+	2,  // 2: proto.QueryRequest.args:type_name -> proto.Value
+	6,  // 3: proto.QueryRequest.statements:type_name -> proto.Statement
+	11, // 4: proto.QueryRequest.deadline:type_name -> google.protobuf.Timestamp
+	5,  // 5: proto.QueryRequest.auth:type_name -> proto.Auth
+	11, // 6: proto.Auth.signed_at:type_name -> google.protobuf.Timestamp
+	2,  // 7: proto.Statement.args:type_name -> proto.Value
+	8,  // 8: proto.WriteQueryReply.result:type_name -> proto.DriverResult
+	0,  // 9: proto.WriteQueryReply.code:type_name -> proto.ErrorCode
+	8,  // 10: proto.WriteQueryReply.results:type_name -> proto.DriverResult
+	2,  // 11: proto.RowUpdate.row:type_name -> proto.Value
+	1,  // 12: proto.RowUpdate.op:type_name -> proto.RowOp
+	2,  // 13: proto.RowUpdate.old_row:type_name -> proto.Value
+	11, // 14: proto.RowUpdate.committed_at:type_name -> google.protobuf.Timestamp
+	11, // 15: proto.DeadLetter.failed_at:type_name -> google.protobuf.Timestamp
+	16, // [16:16] is the sub-list for method output_type
+	16, // [16:16] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
 }
 
 func init() { file_microdb_proto_init() }
@@ -543,7 +1124,7 @@ func file_microdb_proto_init() {
 			}
 		}
 		file_microdb_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*WriteQueryReply); i {
+			switch v := v.(*Auth); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -555,7 +1136,7 @@ func file_microdb_proto_init() {
 			}
 		}
 		file_microdb_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*DriverResult); i {
+			switch v := v.(*Statement); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -567,6 +1148,30 @@ func file_microdb_proto_init() {
 			}
 		}
 		file_microdb_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WriteQueryReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_microdb_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DriverResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_microdb_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*RowUpdate); i {
 			case 0:
 				return &v.state
@@ -578,6 +1183,18 @@ func file_microdb_proto_init() {
 				return nil
 			}
 		}
+		file_microdb_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeadLetter); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	file_microdb_proto_msgTypes[0].OneofWrappers = []interface{}{
 		(*Value_Varchar)(nil),
@@ -592,13 +1209,14 @@ func file_microdb_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_microdb_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   6,
+			NumEnums:      2,
+			NumMessages:   9,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
 		GoTypes:           file_microdb_proto_goTypes,
 		DependencyIndexes: file_microdb_proto_depIdxs,
+		EnumInfos:         file_microdb_proto_enumTypes,
 		MessageInfos:      file_microdb_proto_msgTypes,
 	}.Build()
 	File_microdb_proto = out.File
@@ -2,10 +2,29 @@
 package test
 
 import (
+	"os"
+	"path/filepath"
+	"runtime"
+
 	uuid "github.com/satori/go.uuid"
 )
 
+// DefaultMySQLHost is the host of the test data origin config if MYSQL_HOST is not set.
+const DefaultMySQLHost = "127.0.0.1"
+
 // UUID generates a unique UUID for the duration of a test.
 func UUID() string {
 	return uuid.NewV4().String()
 }
+
+// DataOriginCfg returns the path of the test data origin config. Its connection refers to the
+// MYSQL_HOST environment variable, which is set to DefaultMySQLHost unless it is set already, so
+// that the config could be loaded outside of the test containers.
+func DataOriginCfg() string {
+	if _, ok := os.LookupEnv("MYSQL_HOST"); !ok {
+		os.Setenv("MYSQL_HOST", DefaultMySQLHost) //nolint // Setting an env var only fails on invalid names.
+	}
+
+	_, file, _, _ := runtime.Caller(0) //nolint // The file of this function is always known.
+	return filepath.Join(filepath.Dir(file), "test_dataorigin.yaml")
+}
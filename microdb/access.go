@@ -0,0 +1,62 @@
+package microdb //nolint // Package comment located in a different file.
+
+import (
+	"reflect"
+)
+
+// Write access control.
+
+// Operations allowed by AccessCfg.
+const (
+	OperationInsert = "insert"
+	OperationUpdate = "update"
+)
+
+// AccessCfg represents who may write to a table through queriers, and how.
+//
+//    access:
+//      clients: [orders, billing]
+//      operations: [insert]
+//      named_only: true
+type AccessCfg struct {
+	// Clients are the identities of the clients allowed to write to the table. Any client, signed
+	// or not, is allowed if empty.
+	Clients []string `yaml:"clients,omitempty"`
+	// Operations are the statement types allowed on the table, any of OperationInsert and
+	// OperationUpdate. Both are allowed if empty.
+	Operations []string `yaml:"operations,omitempty"`
+	// NamedOnly only allows the queries of the named statements of the table.
+	NamedOnly bool `yaml:"named_only,omitempty"`
+}
+
+// AllowsClient reports whether a client may write to the table. An empty id is an unsigned client.
+func (a *AccessCfg) AllowsClient(id string) bool {
+	if a == nil || len(a.Clients) == 0 {
+		return true
+	}
+
+	for _, c := range a.Clients {
+		if c == id && id != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsOperation reports whether statements of an operation may write to the table.
+func (a *AccessCfg) AllowsOperation(op string) bool {
+	if a == nil || len(a.Operations) == 0 {
+		return true
+	}
+
+	for _, o := range a.Operations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *DataOrigin) equalAccess(o *DataOrigin) bool {
+	return reflect.DeepEqual(d.Access, o.Access) && reflect.DeepEqual(d.Statements, o.Statements)
+}
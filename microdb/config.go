@@ -2,10 +2,6 @@ package microdb //nolint // Package comment located in a different file.
 
 import (
 	"fmt"
-	"io/ioutil"
-	"path/filepath"
-
-	"gopkg.in/yaml.v3"
 )
 
 // DataOriginCfg represents a config file with all the data origins.
@@ -13,22 +9,37 @@ type DataOriginCfg struct {
 	Origins map[string]*DataOrigin `yaml:",inline"`
 }
 
-// AddDataOriginFromCfg parses a config file and registers the data origins.
+// AddDataOriginFromCfg parses a config file and registers the data origins in the default registry.
 func AddDataOriginFromCfg(name string) error {
-	path := filepath.Clean(name)
-	b, err := ioutil.ReadFile(path)
-	if err != nil {
-		return fmt.Errorf("failed to open config file")
-	}
+	return defaultRegistry.AddDataOriginFromCfg(name)
+}
 
-	var cfg DataOriginCfg
-	if err := yaml.Unmarshal(b, &cfg); err != nil {
+// AddDataOriginFromCfg parses a config file and registers the data origins.
+//
+// The config file is validated before any data origin is registered, see LoadCfg. Tables that are
+// already registered are replaced by the ones in the config file.
+//
+// Connection fields could reference environment variables with ${ENV_VAR}. The dsn of a MySQL
+// data origin and the password could be read from a mounted secret with "file:<path>":
+//
+//    connection:
+//      type: mysql
+//      host: ${MYSQL_HOST}
+//      user: root
+//      password_file: /run/secrets/mysql-password
+//      database: test
+//      tls: preferred
+func (r *Registry) AddDataOriginFromCfg(name string) error {
+	cfg, err := LoadCfg(name)
+	if err != nil {
 		return fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	for t, do := range cfg.Origins {
-		dataOrigins[t] = do
-		schemaStore[t] = do.Schema
+		r.set(t, do)
 	}
 
 	return nil
@@ -0,0 +1,323 @@
+package microdb_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hojulian/microdb/internal/test"
+	"github.com/hojulian/microdb/microdb"
+)
+
+// testCfg reads the test data origin config.
+func testCfg(t *testing.T) []byte {
+	b, err := ioutil.ReadFile(test.DataOriginCfg())
+	if err != nil {
+		t.Fatalf("failed to read test config: %s", err)
+	}
+
+	return b
+}
+
+func TestParseCfg(t *testing.T) {
+	valid := testCfg(t)
+
+	testCases := []struct {
+		desc string
+		cfg  string
+		errs []string
+	}{
+		{
+			desc: "valid config",
+			cfg:  string(valid),
+		},
+		{
+			desc: "unknown field",
+			cfg: strings.Replace(string(valid),
+				"    insert_query:", "    insert_qeury:", 1),
+			errs: []string{"line 23: field insert_qeury not found in type microdb.Schema"},
+		},
+		{
+			desc: "missing insert query",
+			cfg: strings.Replace(string(valid),
+				"    insert_query: REPLACE INTO test VALUES (?, ?, ?, ?, ?, ?);\n", "", 1),
+			errs: []string{"line 2: table test: missing insert_query"},
+		},
+		{
+			desc: "mismatching table name",
+			cfg:  strings.Replace(string(valid), "    table: test", "    table: tset", 1),
+			errs: []string{`line 3: table test: schema table "tset" does not match "test"`},
+		},
+		{
+			desc: "unknown connection type",
+			cfg:  strings.Replace(string(valid), "type: mysql", "type: postgres", 1),
+			errs: []string{`line 25: table test: unknown connection type "postgres"`},
+		},
+		{
+			desc: "insert placeholder count mismatch",
+			cfg: strings.Replace(string(valid),
+				"test VALUES (?, ?, ?, ?, ?, ?)", "test (id, string_type) VALUES (?, ?)", 1),
+			errs: []string{"line 23: table test: invalid insert_query: has 2 placeholders, table has 6 columns"},
+		},
+		{
+			desc: "invalid origin table query",
+			cfg:  strings.Replace(string(valid), "CREATE TABLE test (", "CREATE TABLE test", 1),
+			errs: []string{"line 4: table test: invalid origin_table_query"},
+		},
+		{
+			desc: "access and named statements",
+			cfg: string(valid) + `  access:
+    clients: [orders]
+    operations: [insert]
+    named_only: true
+  statements:
+    create: INSERT INTO test (id) VALUES (?)
+`,
+		},
+		{
+			desc: "unknown access operation",
+			cfg: string(valid) + `  access:
+    operations: [delete]
+`,
+			errs: []string{`line 28: table test: unknown operation "delete"`},
+		},
+		{
+			desc: "invalid named statement",
+			cfg: string(valid) + `  statements:
+    drop:
+      query: DROP TABLE test
+`,
+			errs: []string{`line 29: table test: invalid query of statement "drop": not an insert or update statement`},
+		},
+		{
+			desc: "named statement parameter count mismatch",
+			cfg: string(valid) + `  statements:
+    create:
+      query: INSERT INTO test (id, string_type) VALUES (?, ?)
+      params: [int]
+`,
+			errs: []string{`line 29: table test: invalid query of statement "create": has 2 placeholders, 1 parameters declared`},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			cfg, err := microdb.ParseCfg([]byte(tC.cfg))
+			if len(tC.errs) == 0 {
+				assert.Nil(t, err)
+				assert.Contains(t, cfg.Origins, "test")
+				return
+			}
+
+			var errs microdb.CfgErrors
+			if !assert.ErrorAs(t, err, &errs) {
+				return
+			}
+			assert.Len(t, errs, len(tC.errs))
+			for i, e := range tC.errs {
+				if i < len(errs) {
+					assert.True(t, strings.HasPrefix(errs[i].Error(), e), "got: %s", errs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseCfgConnection(t *testing.T) {
+	valid := testCfg(t)
+
+	secret, err := ioutil.TempFile("", "microdb-secret")
+	if err != nil {
+		t.Fatalf("failed to create secret file: %s", err)
+	}
+	defer os.Remove(secret.Name())
+	if _, err := secret.WriteString("s3cret\n"); err != nil {
+		t.Fatalf("failed to write secret file: %s", err)
+	}
+
+	os.Setenv("MICRODB_TEST_USER", "tester")
+	defer os.Unsetenv("MICRODB_TEST_USER")
+
+	testCases := []struct {
+		desc       string
+		connection string
+		dsn        string
+		err        string
+	}{
+		{
+			desc:       "raw dsn",
+			connection: "    dsn: root:test@/test",
+			dsn:        "root:test@/test",
+		},
+		{
+			desc:       "environment variable in dsn",
+			connection: "    dsn: ${MICRODB_TEST_USER}:test@/test",
+			dsn:        "tester:test@/test",
+		},
+		{
+			desc:       "missing environment variable",
+			connection: "    dsn: ${MICRODB_TEST_MISSING}:test@/test",
+			err:        "line 26: table test: invalid connection dsn: environment variable MICRODB_TEST_MISSING is not set",
+		},
+		{
+			desc: "structured fields",
+			connection: strings.Join([]string{
+				"    host: 127.0.0.1",
+				"    user: ${MICRODB_TEST_USER}",
+				"    password_file: " + secret.Name(),
+				"    database: test",
+				"    tls: skip-verify",
+			}, "\n"),
+			dsn: "tester:s3cret@tcp(127.0.0.1:3306)/test?parseTime=true&tls=skip-verify",
+		},
+		{
+			desc: "password from secret file reference",
+			connection: strings.Join([]string{
+				"    host: 127.0.0.1",
+				"    port: 3307",
+				"    user: root",
+				"    password: file:" + secret.Name(),
+			}, "\n"),
+			dsn: "root:s3cret@tcp(127.0.0.1:3307)/?parseTime=true",
+		},
+		{
+			desc: "dsn and host",
+			connection: strings.Join([]string{
+				"    dsn: root:test@/test",
+				"    host: 127.0.0.1",
+			}, "\n"),
+			err: "line 27: table test: invalid connection host: dsn and host are mutually exclusive",
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			cfg, err := microdb.ParseCfg([]byte(strings.Replace(string(valid),
+				"    dsn: root:test@tcp(${MYSQL_HOST}:3306)/test", tC.connection, 1)))
+			if tC.err != "" {
+				assert.EqualError(t, err, tC.err)
+				return
+			}
+
+			if assert.Nil(t, err) {
+				assert.Equal(t, tC.dsn, cfg.Origins["test"].Connection.Dsn)
+			}
+		})
+	}
+}
+
+func TestReloadCfg(t *testing.T) {
+	valid := testCfg(t)
+	other := strings.ReplaceAll(string(valid), "test", "other")
+
+	testCases := []struct {
+		desc   string
+		before string
+		after  string
+		change *microdb.CfgChange
+		tables []string
+		// closed is whether the database connection of the test table is closed.
+		closed bool
+		// access is whether the test table has an access config after reloading.
+		access bool
+	}{
+		{
+			desc:   "unchanged",
+			before: string(valid),
+			after:  string(valid),
+			change: &microdb.CfgChange{},
+			tables: []string{"test"},
+		},
+		{
+			desc:   "table added",
+			before: string(valid),
+			after:  string(valid) + other,
+			change: &microdb.CfgChange{Added: []string{"other"}},
+			tables: []string{"other", "test"},
+		},
+		{
+			desc:   "table removed",
+			before: string(valid) + other,
+			after:  other,
+			change: &microdb.CfgChange{Removed: []string{"test"}},
+			tables: []string{"other"},
+			closed: true,
+		},
+		{
+			desc:   "table updated",
+			before: string(valid),
+			after:  strings.Replace(string(valid), ":3306)/test", ":3306)/other", 1),
+			change: &microdb.CfgChange{Updated: []string{"test"}},
+			tables: []string{"test"},
+			closed: true,
+		},
+		{
+			desc:   "access updated",
+			before: string(valid),
+			after: string(valid) + `  access:
+    clients: [orders]
+`,
+			change: &microdb.CfgChange{},
+			tables: []string{"test"},
+			access: true,
+		},
+		{
+			desc:   "invalid config",
+			before: string(valid),
+			after:  strings.Replace(string(valid), "type: mysql", "type: postgres", 1),
+			tables: []string{"test"},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "microdb-dataorigin")
+			if err != nil {
+				t.Fatalf("failed to create config file: %s", err)
+			}
+			defer os.Remove(f.Name())
+
+			reg := microdb.NewRegistry()
+			assert.Nil(t, ioutil.WriteFile(f.Name(), []byte(tC.before), 0o600))
+			assert.Nil(t, reg.AddDataOriginFromCfg(f.Name()))
+
+			do, err := reg.GetDataOrigin("test")
+			if err != nil {
+				t.Fatalf("failed to get data origin: %s", err)
+			}
+			db, err := do.GetDB()
+			if err != nil {
+				t.Fatalf("failed to get database connection: %s", err)
+			}
+
+			assert.Nil(t, ioutil.WriteFile(f.Name(), []byte(tC.after), 0o600))
+			change, err := reg.ReloadCfg(f.Name())
+			if tC.change == nil {
+				assert.NotNil(t, err)
+			} else {
+				assert.Equal(t, tC.change, change)
+			}
+			assert.Equal(t, tC.tables, reg.Tables())
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			err = db.PingContext(ctx)
+			assert.Equal(t, tC.closed, err != nil && err.Error() == "sql: database is closed")
+
+			if do, err := reg.GetDataOrigin("test"); err == nil {
+				assert.Equal(t, tC.access, do.Access != nil)
+				if !tC.closed {
+					// The database connection is kept.
+					newDB, err := do.GetDB()
+					assert.Nil(t, err)
+					assert.Same(t, db, newDB)
+				}
+			}
+		})
+	}
+}
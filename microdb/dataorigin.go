@@ -1,8 +1,10 @@
 package microdb //nolint // Package comment located in a different file.
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sync"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/huandu/go-sqlbuilder"
@@ -18,9 +20,6 @@ const (
 	DataOriginTypeSQLite3 = "sqlite3"
 )
 
-//nolint // Used as internal data origin mapping.
-var dataOrigins = make(map[string]*DataOrigin)
-
 // DataOriginType represents a data origin database type.
 type DataOriginType string
 
@@ -42,13 +41,53 @@ func (d *DataOriginType) toBuilderFlavor() sqlbuilder.Flavor {
 type DataOrigin struct {
 	Schema     *Schema        `yaml:"schema"`
 	Connection *ConnectionCfg `yaml:"connection"`
-	db         *sql.DB        `yaml:"-"`
+	// Access restricts writes to the table, see AccessCfg. Writes are not restricted if nil.
+	Access *AccessCfg `yaml:"access,omitempty"`
+	// Statements are the named write statements of the table.
+	Statements map[string]*NamedStatement `yaml:"statements,omitempty"`
+
+	mu sync.Mutex `yaml:"-"`
+	db *sql.DB    `yaml:"-"`
 }
 
 // ConnectionCfg represents all the info for connecting to the data origin.
+//
+// A connection is configured either with a raw Dsn or, for MySQL-based data origins, with the
+// structured fields. All string fields support ${ENV_VAR} interpolation, and Dsn and Password
+// could reference a mounted secret using "file:<path>", see AddDataOriginFromCfg.
 type ConnectionCfg struct {
 	OriginType DataOriginType `yaml:"type"`
-	Dsn        string         `yaml:"dsn"`
+	Dsn        string         `yaml:"dsn,omitempty"`
+
+	Host         string `yaml:"host,omitempty"`
+	Port         string `yaml:"port,omitempty"`
+	User         string `yaml:"user,omitempty"`
+	Password     string `yaml:"password,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+	Database     string `yaml:"database,omitempty"`
+	TLS          string `yaml:"tls,omitempty"`
+}
+
+// MySQLConfig parses the Dsn of a MySQL-based data origin.
+func (c *ConnectionCfg) MySQLConfig() (*mysql.Config, error) {
+	if c.OriginType != DataOriginTypeMySQL {
+		return nil, fmt.Errorf("not a %s data origin, got: %s", DataOriginTypeMySQL, c.OriginType)
+	}
+
+	m, err := mysql.ParseDSN(c.Dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dsn: %w", err)
+	}
+
+	return m, nil
+}
+
+// String returns a description of the connection without its credentials.
+func (c *ConnectionCfg) String() string {
+	if m, err := c.MySQLConfig(); err == nil {
+		return fmt.Sprintf("%s://%s/%s", c.OriginType, m.Addr, m.DBName)
+	}
+	return string(c.OriginType)
 }
 
 // DataOriginOption represents options for creating a DataOrigin.
@@ -63,7 +102,7 @@ func WithMySQLDataOrigin(host, port, user, password, database string, opt Schema
 			return nil, fmt.Errorf("invalid schema: %w", err)
 		}
 
-		cfg := mySQLConnectionCfg(host, port, user, password, database)
+		cfg := mySQLConnectionCfg(host, port, user, password, database, "")
 
 		return &DataOrigin{
 			Schema:     s,
@@ -72,7 +111,7 @@ func WithMySQLDataOrigin(host, port, user, password, database string, opt Schema
 	}
 }
 
-func mySQLConnectionCfg(host, port, user, password, database string) *ConnectionCfg {
+func mySQLConnectionCfg(host, port, user, password, database, tls string) *ConnectionCfg {
 	mCfg := mysql.NewConfig()
 	mCfg.Net = "tcp"
 	mCfg.Addr = fmt.Sprintf("%s:%s", host, port)
@@ -80,6 +119,7 @@ func mySQLConnectionCfg(host, port, user, password, database string) *Connection
 	mCfg.Passwd = password
 	mCfg.DBName = database
 	mCfg.ParseTime = true
+	mCfg.TLSConfig = tls
 
 	return &ConnectionCfg{
 		OriginType: DataOriginTypeMySQL,
@@ -87,30 +127,14 @@ func mySQLConnectionCfg(host, port, user, password, database string) *Connection
 	}
 }
 
-// AddDataOrigin adds a new data origin.
+// AddDataOrigin adds a new data origin to the default registry.
 func AddDataOrigin(table string, opt DataOriginOption) error {
-	if _, ok := dataOrigins[table]; ok {
-		return nil
-	}
-
-	d, err := opt()
-	if err != nil {
-		return fmt.Errorf("failed to add data origin: %w", err)
-	}
-
-	dataOrigins[table] = d
-	schemaStore[table] = d.Schema
-	return nil
+	return defaultRegistry.AddDataOrigin(table, opt)
 }
 
-// GetDataOrigin retreives a DataOrigin given the table name.
+// GetDataOrigin retreives a DataOrigin from the default registry given the table name.
 func GetDataOrigin(table string) (*DataOrigin, error) {
-	d, ok := dataOrigins[table]
-	if !ok {
-		return nil, fmt.Errorf("no such table, got: %s", table)
-	}
-
-	return d, nil
+	return defaultRegistry.GetDataOrigin(table)
 }
 
 // GetDB returns a database connection to a specific data origin.
@@ -118,6 +142,9 @@ func (d *DataOrigin) GetDB() (*sql.DB, error) {
 	var db *sql.DB
 	var err error
 
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	if d.db != nil {
 		return d.db, nil
 	}
@@ -137,6 +164,31 @@ func (d *DataOrigin) GetDB() (*sql.DB, error) {
 	return db, nil
 }
 
+// closeDB closes the database connection of a data origin once it is no longer registered. Later
+// calls to GetDB return the closed connection.
+func (d *DataOrigin) closeDB() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.db != nil {
+		d.db.Close() //nolint // Best effort clean up, queries of a closed connection fail anyway.
+	}
+}
+
+// Ping verifies that the data origin is reachable.
+func (d *DataOrigin) Ping(ctx context.Context) error {
+	db, err := d.GetDB()
+	if err != nil {
+		return err
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping data origin: %w", err)
+	}
+
+	return nil
+}
+
 // ReadTopic returns the NATS topic name for subscribe to a table's updates.
 func (d *DataOrigin) ReadTopic() string {
 	return fmt.Sprintf("%s_table", d.Schema.Table)
@@ -146,3 +198,20 @@ func (d *DataOrigin) ReadTopic() string {
 func (d *DataOrigin) WriteTopic() string {
 	return fmt.Sprintf("%s_write", d.Schema.Table)
 }
+
+// DeadLetterTopic returns the NATS topic name for table updates that clients failed to apply.
+func (d *DataOrigin) DeadLetterTopic() string {
+	return fmt.Sprintf("%s_deadletter", d.Schema.Table)
+}
+
+// ReplayTopic returns the NATS topic name for dead letters of a table replayed to the client with
+// the given NATS client ID.
+func (d *DataOrigin) ReplayTopic(clientID string) string {
+	return fmt.Sprintf("%s_replay.%s", d.Schema.Table, clientID)
+}
+
+// WriteQueueGroup returns the NATS queue group that queriers of a table join, so that each write
+// is handled by a single querier.
+func (d *DataOrigin) WriteQueueGroup() string {
+	return fmt.Sprintf("%s_querier", d.Schema.Table)
+}
@@ -0,0 +1,122 @@
+package microdb //nolint // Package comment located in a different file.
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Connection config interpolation.
+
+const secretFilePrefix = "file:"
+
+//nolint // Used for matching ${ENV_VAR} references.
+var envVarRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// interpolate replaces all ${ENV_VAR} references in s with the value of the environment variable.
+func interpolate(s string) (string, error) {
+	var err error
+
+	r := envVarRegexp.ReplaceAllStringFunc(s, func(m string) string {
+		name := envVarRegexp.FindStringSubmatch(m)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s is not set", name)
+		}
+		return v
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return r, nil
+}
+
+// readSecretFile returns the content of a mounted secret file without the trailing newline.
+func readSecretFile(name string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Clean(name))
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// resolve interpolates environment variables, reads secret files and builds the Dsn from the
+// structured fields if needed.
+//
+// On error, the yaml name of the offending field is returned along with the error.
+//nolint // Resolution of every field happens in one place for readability.
+func (c *ConnectionCfg) resolve() (string, error) {
+	fields := []struct {
+		name string
+		v    *string
+	}{
+		{"dsn", &c.Dsn},
+		{"host", &c.Host},
+		{"port", &c.Port},
+		{"user", &c.User},
+		{"password", &c.Password},
+		{"password_file", &c.PasswordFile},
+		{"database", &c.Database},
+		{"tls", &c.TLS},
+	}
+	for _, f := range fields {
+		v, err := interpolate(*f.v)
+		if err != nil {
+			return f.name, err
+		}
+		*f.v = v
+	}
+
+	// SQLite3 uses "file:" for URI filenames, so secret files are only supported for MySQL.
+	if c.OriginType == DataOriginTypeMySQL && strings.HasPrefix(c.Dsn, secretFilePrefix) {
+		v, err := readSecretFile(strings.TrimPrefix(c.Dsn, secretFilePrefix))
+		if err != nil {
+			return "dsn", err
+		}
+		c.Dsn = v
+	}
+
+	if strings.HasPrefix(c.Password, secretFilePrefix) {
+		v, err := readSecretFile(strings.TrimPrefix(c.Password, secretFilePrefix))
+		if err != nil {
+			return "password", err
+		}
+		c.Password = v
+	}
+
+	if c.PasswordFile != "" {
+		if c.Password != "" {
+			return "password_file", fmt.Errorf("password and password_file are mutually exclusive")
+		}
+
+		v, err := readSecretFile(c.PasswordFile)
+		if err != nil {
+			return "password_file", err
+		}
+		c.Password = v
+	}
+
+	if c.Host == "" {
+		return "", nil
+	}
+
+	if c.Dsn != "" {
+		return "host", fmt.Errorf("dsn and host are mutually exclusive")
+	}
+	if c.OriginType != DataOriginTypeMySQL {
+		return "host", fmt.Errorf("structured connection fields require connection type %s", DataOriginTypeMySQL)
+	}
+
+	port := c.Port
+	if port == "" {
+		port = "3306"
+	}
+	c.Dsn = mySQLConnectionCfg(c.Host, port, c.User, c.Password, c.Database, c.TLS).Dsn
+
+	return "", nil
+}
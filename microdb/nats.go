@@ -1,6 +1,7 @@
 package microdb //nolint // Package comment located in a different file.
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -10,20 +11,56 @@ import (
 	"github.com/nats-io/stan.go"
 )
 
-// NATSConnFromEnv create a NATS connection from environment variables.
+// Default NATS connection settings, if not set in the environment.
+const (
+	DefaultNATSHost = "127.0.0.1"
+	DefaultNATSPort = "4222"
+)
+
+// NATSCfg represents the settings of a NATS connection.
+type NATSCfg struct {
+	Host      string
+	Port      string
+	ClusterID string
+	ClientID  string
+}
+
+// NATSCfgFromEnv reads the NATS connection settings from the NATS_HOST, NATS_PORT,
+// NATS_CLUSTER_ID and NATS_CLIENT_ID environment variables. The host and port default to
+// DefaultNATSHost and DefaultNATSPort.
+func NATSCfgFromEnv() *NATSCfg {
+	c := &NATSCfg{
+		Host:      os.Getenv("NATS_HOST"),
+		Port:      os.Getenv("NATS_PORT"),
+		ClusterID: os.Getenv("NATS_CLUSTER_ID"),
+		ClientID:  os.Getenv("NATS_CLIENT_ID"),
+	}
+	if c.Host == "" {
+		c.Host = DefaultNATSHost
+	}
+	if c.Port == "" {
+		c.Port = DefaultNATSPort
+	}
+
+	return c
+}
+
+// NATSConnFromEnv create a NATS connection from environment variables, see NATSCfgFromEnv.
 func NATSConnFromEnv() (stan.Conn, error) {
-	var (
-		natsHost      = os.Getenv("NATS_HOST")
-		natsPort      = os.Getenv("NATS_PORT")
-		natsClusterID = os.Getenv("NATS_CLUSTER_ID")
-		natsClientID  = os.Getenv("NATS_CLIENT_ID")
-	)
-
-	return NATSConn(natsHost, natsPort, natsClusterID, natsClientID, nil, nil)
+	c := NATSCfgFromEnv()
+
+	return NATSConn(c.Host, c.Port, c.ClusterID, c.ClientID, nil, nil)
 }
 
 // NATSConn creates a NATS connection.
 func NATSConn(host, port, clusterID, clientID string, sOpts []stan.Option, nOpts []nats.Option) (stan.Conn, error) {
+	if clusterID == "" {
+		return nil, errors.New("failed to connect to nats: empty NATS streaming cluster ID")
+	}
+	if clientID == "" {
+		return nil, errors.New("failed to connect to nats: empty NATS streaming client ID")
+	}
+
 	var nc *nats.Conn
 	var sc stan.Conn
 	var err error
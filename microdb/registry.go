@@ -0,0 +1,167 @@
+package microdb //nolint // Package comment located in a different file.
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+//nolint // Used as the registry behind the package level functions.
+var defaultRegistry = NewRegistry()
+
+// Registry represents a set of data origins and their table schemas.
+//
+// A Registry is safe for concurrent use. Processes that talk to more than one environment could
+// create a Registry for each of them and pass it to the client, publisher and querier.
+type Registry struct {
+	mu          sync.RWMutex
+	dataOrigins map[string]*DataOrigin
+	schemas     map[string]*Schema
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		dataOrigins: make(map[string]*DataOrigin),
+		schemas:     make(map[string]*Schema),
+	}
+}
+
+// DefaultRegistry returns the registry used by the package level functions.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// AddDataOrigin adds a new data origin.
+//
+// Adding a table that is already registered is a no-op.
+func (r *Registry) AddDataOrigin(table string, opt DataOriginOption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.dataOrigins[table]; ok {
+		return nil
+	}
+
+	d, err := opt()
+	if err != nil {
+		return fmt.Errorf("failed to add data origin: %w", err)
+	}
+
+	r.set(table, d)
+	return nil
+}
+
+func (r *Registry) set(table string, d *DataOrigin) {
+	r.dataOrigins[table] = d
+	r.schemas[table] = d.Schema
+}
+
+// GetDataOrigin retreives a DataOrigin given the table name.
+func (r *Registry) GetDataOrigin(table string) (*DataOrigin, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	d, ok := r.dataOrigins[table]
+	if !ok {
+		return nil, fmt.Errorf("no such table, got: %s", table)
+	}
+
+	return d, nil
+}
+
+// Tables returns the names of all registered tables in sorted order.
+func (r *Registry) Tables() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ts := make([]string, 0, len(r.dataOrigins))
+	for t := range r.dataOrigins {
+		ts = append(ts, t)
+	}
+	sort.Strings(ts)
+
+	return ts
+}
+
+// OriginGroup represents the tables sharing the same data origin connection.
+type OriginGroup struct {
+	Connection *ConnectionCfg
+	Tables     []string
+}
+
+// Key identifies the data origin connection of the group.
+func (g *OriginGroup) Key() string {
+	return string(g.Connection.OriginType) + " " + g.Connection.Dsn
+}
+
+// Groups returns all registered tables grouped by data origin connection.
+//
+// Groups are sorted by connection, and tables are sorted by name.
+func (r *Registry) Groups() []*OriginGroup {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	groups := make(map[string]*OriginGroup)
+	for t, do := range r.dataOrigins {
+		g := &OriginGroup{Connection: do.Connection}
+		if eg, ok := groups[g.Key()]; ok {
+			g = eg
+		} else {
+			groups[g.Key()] = g
+		}
+		g.Tables = append(g.Tables, t)
+	}
+
+	gs := make([]*OriginGroup, 0, len(groups))
+	for _, g := range groups {
+		sort.Strings(g.Tables)
+		gs = append(gs, g)
+	}
+	sort.Slice(gs, func(i, j int) bool { return gs[i].Key() < gs[j].Key() })
+
+	return gs
+}
+
+func (r *Registry) schema(table string) (*Schema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.schemas[table]
+	if !ok {
+		return nil, errors.New("no such table")
+	}
+
+	return s, nil
+}
+
+// LocalTableQuery returns the create table query (sqlite3) for a given table.
+func (r *Registry) LocalTableQuery(table string) (string, error) {
+	s, err := r.schema(table)
+	if err != nil {
+		return "", err
+	}
+
+	return s.LocalTableQuery, nil
+}
+
+// OriginTableQuery returns the create table query (origin) for a given table.
+func (r *Registry) OriginTableQuery(table string) (string, error) {
+	s, err := r.schema(table)
+	if err != nil {
+		return "", err
+	}
+
+	return s.OriginTableQuery, nil
+}
+
+// InsertQuery returns the insert query (sqlite3) for a given table.
+func (r *Registry) InsertQuery(table string) (string, error) {
+	s, err := r.schema(table)
+	if err != nil {
+		return "", err
+	}
+
+	return s.InsertQuery, nil
+}
@@ -0,0 +1,59 @@
+package microdb_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hojulian/microdb/internal/test"
+	"github.com/hojulian/microdb/microdb"
+)
+
+func TestRegistry(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		tables []string
+	}{
+		{
+			desc:   "one table",
+			tables: []string{"test"},
+		},
+		{
+			desc:   "many tables",
+			tables: []string{"c", "a", "b"},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			reg := microdb.NewRegistry()
+
+			var wg sync.WaitGroup
+			for _, table := range tC.tables {
+				wg.Add(1)
+				go func(table string) {
+					defer wg.Done()
+					assert.Nil(t, reg.AddDataOrigin(table, microdb.WithMySQLDataOrigin(
+						"127.0.0.1", "3306", "root", "test", "test", test.TestSchemaOption)))
+				}(table)
+			}
+			wg.Wait()
+
+			assert.ElementsMatch(t, tC.tables, reg.Tables())
+			for _, table := range tC.tables {
+				do, err := reg.GetDataOrigin(table)
+				assert.Nil(t, err)
+				assert.NotNil(t, do)
+
+				iq, err := reg.InsertQuery(table)
+				assert.Nil(t, err)
+				assert.NotEmpty(t, iq)
+			}
+
+			_, err := reg.GetDataOrigin("missing")
+			assert.Equal(t, fmt.Errorf("no such table, got: %s", "missing"), err)
+		})
+	}
+}
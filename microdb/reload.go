@@ -0,0 +1,146 @@
+package microdb //nolint // Package comment located in a different file.
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Config reloading.
+
+// CfgChange represents the tables that changed between two versions of a data origin config.
+type CfgChange struct {
+	Added   []string
+	Removed []string
+	Updated []string
+}
+
+// Empty reports whether no table changed.
+func (c *CfgChange) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Updated) == 0
+}
+
+// ReloadCfg replaces the data origins in the registry with the ones in a config file.
+//
+// Unlike AddDataOriginFromCfg, tables missing from the config file are removed from the registry.
+// Data origins that did not change are kept, so their database connections are reused, and the
+// database connections of updated and removed data origins are closed. Data origins whose access
+// config or statements changed only are replaced without closing their database connection, and
+// are not reported as updated. If the config file is invalid, the registry is left untouched.
+func (r *Registry) ReloadCfg(name string) (*CfgChange, error) {
+	cfg, err := LoadCfg(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	change, replaced := r.reload(cfg)
+
+	// Closing waits for the queries already running on the replaced data origins.
+	for _, do := range replaced {
+		do.closeDB()
+	}
+
+	return change, nil
+}
+
+// reload replaces the data origins of the registry, and returns the ones replaced or removed.
+func (r *Registry) reload(cfg *DataOriginCfg) (*CfgChange, []*DataOrigin) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	change := &CfgChange{}
+	var replaced []*DataOrigin
+	for t, do := range cfg.Origins {
+		old, ok := r.dataOrigins[t]
+		switch {
+		case !ok:
+			change.Added = append(change.Added, t)
+		case !old.equal(do):
+			change.Updated = append(change.Updated, t)
+			replaced = append(replaced, old)
+		case !old.equalAccess(do):
+			// Access configs and statements are looked up with every write, so they are applied
+			// without reconnecting.
+			do.shareDB(old)
+		default:
+			continue
+		}
+		r.set(t, do)
+	}
+
+	for t, do := range r.dataOrigins {
+		if _, ok := cfg.Origins[t]; !ok {
+			change.Removed = append(change.Removed, t)
+			replaced = append(replaced, do)
+			delete(r.dataOrigins, t)
+			delete(r.schemas, t)
+		}
+	}
+
+	sort.Strings(change.Added)
+	sort.Strings(change.Removed)
+	sort.Strings(change.Updated)
+
+	return change, replaced
+}
+
+// equal reports whether two data origins have the same connection and schema, i.e. whether their
+// publishers and queriers could be kept.
+func (d *DataOrigin) equal(o *DataOrigin) bool {
+	if (d.Schema == nil) != (o.Schema == nil) || (d.Connection == nil) != (o.Connection == nil) {
+		return false
+	}
+	if d.Schema != nil && *d.Schema != *o.Schema {
+		return false
+	}
+	return d.Connection == nil || *d.Connection == *o.Connection
+}
+
+// shareDB uses the database connection of another data origin of the same connection.
+func (d *DataOrigin) shareDB(o *DataOrigin) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.db = o.db
+}
+
+// WatchFile polls a file and sends on the returned channel whenever its modification time or size
+// changes. The channel is closed once ctx is done.
+//
+// Notifications are coalesced, a slow receiver only gets the latest one.
+func WatchFile(ctx context.Context, name string, interval time.Duration) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+
+	go func() {
+		defer close(ch)
+
+		last, _ := os.Stat(name)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+
+			fi, err := os.Stat(name)
+			if err != nil || (last != nil && fi.ModTime().Equal(last.ModTime()) && fi.Size() == last.Size()) {
+				continue
+			}
+			last = fi
+
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return ch
+}
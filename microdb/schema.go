@@ -6,9 +6,6 @@ import (
 	sqlbuilder "github.com/huandu/go-sqlbuilder"
 )
 
-//nolint // Used as internal schema mapping.
-var schemaStore = make(map[string]*Schema)
-
 // Schema represents the SQL schema for a table.
 type Schema struct {
 	Table            string `yaml:"table"`
@@ -79,30 +76,15 @@ func WithSchemaBuilder(
 
 // LocalTableQuery returns the create table query (sqlite3) for a given table.
 func LocalTableQuery(table string) (string, error) {
-	s, ok := schemaStore[table]
-	if !ok {
-		return "", errors.New("no such table")
-	}
-
-	return s.LocalTableQuery, nil
+	return defaultRegistry.LocalTableQuery(table)
 }
 
 // OriginTableQuery returns the create table query (origin) for a given table.
 func OriginTableQuery(table string) (string, error) {
-	s, ok := schemaStore[table]
-	if !ok {
-		return "", errors.New("no such table")
-	}
-
-	return s.OriginTableQuery, nil
+	return defaultRegistry.OriginTableQuery(table)
 }
 
 // InsertQuery returns the insert query (sqlite3) for a given table.
 func InsertQuery(table string) (string, error) {
-	s, ok := schemaStore[table]
-	if !ok {
-		return "", errors.New("no such table")
-	}
-
-	return s.InsertQuery, nil
+	return defaultRegistry.InsertQuery(table)
 }
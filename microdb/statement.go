@@ -0,0 +1,155 @@
+package microdb //nolint // Package comment located in a different file.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cube2222/octosql/parser/sqlparser"
+	"gopkg.in/yaml.v3"
+)
+
+// Named statements.
+
+// Parameter types of named statements. A type suffixed with "?", e.g. "string?", also allows NULL.
+const (
+	ParamString    = "string"
+	ParamInt       = "int"
+	ParamFloat     = "float"
+	ParamBool      = "bool"
+	ParamTimestamp = "timestamp"
+)
+
+// NamedStatement represents a write query declared in the config of a table, and the types of its
+// placeholder parameters. The types are not checked if Params is empty.
+//
+//    statements:
+//      create_user:
+//        query: INSERT INTO users (id, name, email) VALUES (?, ?, ?)
+//        params: [int, string, string?]
+//      delete_user: UPDATE users SET deleted = 1 WHERE id = ?
+type NamedStatement struct {
+	Query  string   `yaml:"query"`
+	Params []string `yaml:"params,omitempty"`
+}
+
+// UnmarshalYAML allows declaring a statement with its query only.
+func (s *NamedStatement) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		s.Query = n.Value
+		return nil
+	}
+
+	type plain NamedStatement
+	return n.Decode((*plain)(s)) //nolint // Decoding errors are reported with their line already.
+}
+
+// CheckArgs checks the args of the placeholder parameters of the statement against its declared
+// parameters. Args are the Go types of MicroDB values, i.e. string, int64, float32, bool,
+// time.Time or nil.
+func (s *NamedStatement) CheckArgs(args []interface{}) error {
+	if len(s.Params) == 0 {
+		return nil
+	}
+	if len(args) != len(s.Params) {
+		return fmt.Errorf("got %d args, statement has %d parameters", len(args), len(s.Params))
+	}
+
+	for i, a := range args {
+		p := s.Params[i]
+		nullable := strings.HasSuffix(p, "?")
+		p = strings.TrimSuffix(p, "?")
+
+		var ok bool
+		switch a.(type) {
+		case nil:
+			ok = nullable
+		case string:
+			ok = p == ParamString
+		case int64:
+			ok = p == ParamInt || p == ParamFloat
+		case float32:
+			ok = p == ParamFloat
+		case bool:
+			ok = p == ParamBool
+		case time.Time:
+			ok = p == ParamTimestamp
+		}
+		if !ok {
+			return fmt.Errorf("arg %d: got %T, parameter is %s", i, a, s.Params[i])
+		}
+	}
+
+	return nil
+}
+
+// MatchStatement returns the name of the named statement of a table whose query is query, ignoring
+// differences in whitespace.
+func (d *DataOrigin) MatchStatement(query string) (string, bool) {
+	q := normalizeQuery(query)
+	for name, s := range d.Statements {
+		if s != nil && normalizeQuery(s.Query) == q {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Statement returns a named statement of a table.
+func (d *DataOrigin) Statement(name string) (*NamedStatement, error) {
+	s, ok := d.Statements[name]
+	if !ok || s == nil {
+		return nil, fmt.Errorf("unknown statement %q of table %s", name, d.Schema.Table)
+	}
+	return s, nil
+}
+
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// validateStatement checks that a named statement is a write query, and that its declared
+// parameters match its placeholders.
+func validateStatement(s *NamedStatement) error {
+	stmt, err := sqlparser.Parse(s.Query)
+	if err != nil {
+		return fmt.Errorf("failed to parse query: %w", err)
+	}
+
+	switch stmt.(type) {
+	case *sqlparser.Insert, *sqlparser.Update:
+	default:
+		return errors.New("not an insert or update statement")
+	}
+
+	if len(s.Params) == 0 {
+		return nil
+	}
+
+	for _, p := range s.Params {
+		switch strings.TrimSuffix(p, "?") {
+		case ParamString, ParamInt, ParamFloat, ParamBool, ParamTimestamp:
+		default:
+			return fmt.Errorf("unknown parameter type %q", p)
+		}
+	}
+
+	if n := countPlaceholders(stmt); n != len(s.Params) {
+		return fmt.Errorf("has %d placeholders, %d parameters declared", n, len(s.Params))
+	}
+
+	return nil
+}
+
+func countPlaceholders(stmt sqlparser.Statement) int {
+	var placeholders int
+	_ = sqlparser.Walk(func(n sqlparser.SQLNode) (bool, error) {
+		if v, ok := n.(*sqlparser.SQLVal); ok && v.Type == sqlparser.ValArg {
+			placeholders++
+		}
+		return true, nil
+	}, stmt)
+
+	return placeholders
+}
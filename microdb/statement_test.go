@@ -0,0 +1,57 @@
+package microdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hojulian/microdb/microdb"
+)
+
+func TestCheckArgs(t *testing.T) {
+	s := &microdb.NamedStatement{
+		Query:  "INSERT INTO test (id, string_type, float_type, timestamp_type) VALUES (?, ?, ?, ?)",
+		Params: []string{"int", "string?", "float", "timestamp"},
+	}
+
+	testCases := []struct {
+		desc string
+		args []interface{}
+		ok   bool
+	}{
+		{
+			desc: "matching args",
+			args: []interface{}{int64(1), "test", float32(1.5), time.Now()},
+			ok:   true,
+		},
+		{
+			desc: "null nullable parameter, int float parameter",
+			args: []interface{}{int64(1), nil, int64(2), time.Now()},
+			ok:   true,
+		},
+		{
+			desc: "null parameter",
+			args: []interface{}{nil, "test", float32(1.5), time.Now()},
+		},
+		{
+			desc: "mismatching type",
+			args: []interface{}{"1", "test", float32(1.5), time.Now()},
+		},
+		{
+			desc: "missing args",
+			args: []interface{}{int64(1)},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			err := s.CheckArgs(tC.args)
+			if tC.ok {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+			}
+		})
+	}
+}
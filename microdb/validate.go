@@ -0,0 +1,397 @@
+package microdb //nolint // Package comment located in a different file.
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cube2222/octosql/parser/sqlparser"
+	"gopkg.in/yaml.v3"
+)
+
+// Config validation.
+
+//nolint // Used for extracting line numbers from yaml errors.
+var yamlLineRegexp = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// CfgError represents a problem found at a specific line of a data origin config file.
+type CfgError struct {
+	Line  int
+	Table string
+	Msg   string
+}
+
+// Error returns the error message prefixed with its line number.
+func (e *CfgError) Error() string {
+	if e.Table == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf("line %d: table %s: %s", e.Line, e.Table, e.Msg)
+}
+
+// CfgErrors represents all the problems found in a data origin config file.
+type CfgErrors []*CfgError
+
+// Error returns all error messages, one per line.
+func (es CfgErrors) Error() string {
+	msgs := make([]string, 0, len(es))
+	for _, e := range es {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// LoadCfg reads and validates a data origin config file.
+func LoadCfg(name string) (*DataOriginCfg, error) {
+	path := filepath.Clean(name)
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+
+	return ParseCfg(b)
+}
+
+// ParseCfg parses and validates a data origin config.
+//
+// Unknown fields, missing queries, invalid table definitions and mismatching insert queries are
+// reported as CfgErrors. Connection configs are resolved, see ConnectionCfg.
+func ParseCfg(b []byte) (*DataOriginCfg, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return nil, yamlCfgErrors(err)
+	}
+
+	var cfg DataOriginCfg
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, yamlCfgErrors(err)
+	}
+
+	if errs := validateCfg(&cfg, &root); len(errs) > 0 {
+		return nil, errs
+	}
+
+	return &cfg, nil
+}
+
+func yamlCfgErrors(err error) error {
+	var msgs []string
+
+	var terr *yaml.TypeError
+	if errors.As(err, &terr) {
+		msgs = terr.Errors
+	} else {
+		msgs = []string{err.Error()}
+	}
+
+	errs := make(CfgErrors, 0, len(msgs))
+	for _, m := range msgs {
+		ce := &CfgError{Msg: m}
+		if sm := yamlLineRegexp.FindStringSubmatch(m); sm != nil {
+			ce.Line, _ = strconv.Atoi(sm[1])
+			ce.Msg = sm[2]
+		}
+		errs = append(errs, ce)
+	}
+
+	return errs
+}
+
+//nolint // Validation of every field happens in one place for readability.
+func validateCfg(cfg *DataOriginCfg, root *yaml.Node) CfgErrors {
+	var errs CfgErrors
+
+	ldb, err := openValidationDB()
+	if err != nil {
+		return CfgErrors{{Msg: err.Error()}}
+	}
+	if ldb != nil {
+		defer ldb.Close()
+	}
+
+	tables := make([]string, 0, len(cfg.Origins))
+	for t := range cfg.Origins {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+
+	for _, t := range tables {
+		do := cfg.Origins[t]
+		tNode := mappingKey(doc, t)
+		report := func(n *yaml.Node, format string, args ...interface{}) {
+			errs = append(errs, &CfgError{Line: n.Line, Table: t, Msg: fmt.Sprintf(format, args...)})
+		}
+
+		if do == nil {
+			report(tNode, "empty data origin")
+			continue
+		}
+		tValue := mappingValue(doc, t)
+
+		if do.Connection == nil {
+			report(tNode, "missing connection")
+		} else {
+			cNode := mappingValue(tValue, "connection")
+			switch do.Connection.OriginType {
+			case DataOriginTypeMySQL, DataOriginTypeSQLite3:
+			case "":
+				report(mappingKey(tValue, "connection"), "missing connection type")
+			default:
+				report(mappingValue(cNode, "type"), "unknown connection type %q", do.Connection.OriginType)
+			}
+			if field, err := do.Connection.resolve(); err != nil {
+				report(mappingValue(cNode, field), "invalid connection %s: %s", field, err)
+			} else if do.Connection.Dsn == "" {
+				report(mappingKey(tValue, "connection"), "missing connection dsn or host")
+			}
+		}
+
+		s := do.Schema
+		if s == nil {
+			report(tNode, "missing schema")
+			continue
+		}
+		sKey := mappingKey(tValue, "schema")
+		sNode := mappingValue(tValue, "schema")
+
+		if s.Table != t {
+			report(mappingValue(sNode, "table"), "schema table %q does not match %q", s.Table, t)
+		}
+
+		if s.OriginTableQuery == "" {
+			report(sKey, "missing origin_table_query")
+		} else if do.Connection != nil {
+			if err := validateOriginTableQuery(do.Connection.OriginType, t, s.OriginTableQuery); err != nil {
+				report(mappingValue(sNode, "origin_table_query"), "invalid origin_table_query: %s", err)
+			}
+		}
+
+		var columns int
+		if s.LocalTableQuery == "" {
+			report(sKey, "missing local_table_query")
+		} else {
+			columns, err = validateLocalTableQuery(ldb, t, s.LocalTableQuery)
+			if err != nil {
+				report(mappingValue(sNode, "local_table_query"), "invalid local_table_query: %s", err)
+			}
+		}
+
+		if s.InsertQuery == "" {
+			report(sKey, "missing insert_query")
+		} else if columns > 0 {
+			if err := validateInsertQuery(ldb, s.InsertQuery, columns); err != nil {
+				report(mappingValue(sNode, "insert_query"), "invalid insert_query: %s", err)
+			}
+		}
+
+		if a := do.Access; a != nil {
+			aNode := mappingValue(tValue, "access")
+			for _, op := range a.Operations {
+				if op != OperationInsert && op != OperationUpdate {
+					report(mappingValue(aNode, "operations"), "unknown operation %q", op)
+				}
+			}
+			if a.NamedOnly && len(do.Statements) == 0 {
+				report(mappingValue(aNode, "named_only"), "named_only without statements")
+			}
+		}
+
+		stNode := mappingValue(tValue, "statements")
+		for name, st := range do.Statements {
+			if st == nil || st.Query == "" {
+				report(mappingKey(stNode, name), "missing query of statement %q", name)
+				continue
+			}
+			if err := validateStatement(st); err != nil {
+				report(mappingValue(stNode, name), "invalid query of statement %q: %s", name, err)
+			}
+		}
+	}
+
+	return errs
+}
+
+func validateOriginTableQuery(originType DataOriginType, table, query string) error {
+	switch originType {
+	case DataOriginTypeMySQL:
+		stmt, err := sqlparser.Parse(query)
+		if err != nil {
+			return fmt.Errorf("failed to parse query: %w", err)
+		}
+
+		ddl, ok := stmt.(*sqlparser.DDL)
+		if !ok || ddl.Action != sqlparser.CreateStr || ddl.TableSpec == nil {
+			return errors.New("not a create table statement")
+		}
+
+		if n := ddl.Table.Name.String(); n != table {
+			return fmt.Errorf("creates table %q instead of %q", n, table)
+		}
+
+	case DataOriginTypeSQLite3:
+		db, err := openValidationDB()
+		if err != nil {
+			return err
+		}
+		if db != nil {
+			defer db.Close()
+		}
+
+		if _, err := validateLocalTableQuery(db, table, query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// openValidationDB opens an in-memory sqlite3 database to validate queries with. It returns nil if
+// the binary was built without cgo, as the sqlite3 driver is only a stub then.
+func openValidationDB() (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open validation database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close() //nolint // Best effort clean up.
+		return nil, nil
+	}
+
+	return db, nil
+}
+
+// validateLocalTableQuery creates the table in db and returns its number of columns.
+//
+// Without db, the query is only parsed. Since the parser does not know every sqlite3 syntax, queries
+// that it fails to parse are not reported, and the number of columns is 0 if it is unknown.
+func validateLocalTableQuery(db *sql.DB, table, query string) (int, error) {
+	if db == nil {
+		return parseLocalTableQuery(table, query)
+	}
+
+	if _, err := db.Exec(query); err != nil {
+		return 0, fmt.Errorf("failed to create table: %w", err)
+	}
+
+	var columns int
+	if err := db.QueryRow(
+		"SELECT COUNT(*) FROM pragma_table_info(?)", table).Scan(&columns); err != nil {
+		return 0, fmt.Errorf("failed to read table columns: %w", err)
+	}
+
+	if columns == 0 {
+		return 0, fmt.Errorf("does not create table %q", table)
+	}
+
+	return columns, nil
+}
+
+func parseLocalTableQuery(table, query string) (int, error) {
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return 0, nil
+	}
+
+	ddl, ok := stmt.(*sqlparser.DDL)
+	if !ok || ddl.Action != sqlparser.CreateStr {
+		return 0, errors.New("not a create table statement")
+	}
+
+	if n := ddl.Table.Name.String(); n != table {
+		return 0, fmt.Errorf("does not create table %q", table)
+	}
+
+	if ddl.TableSpec == nil {
+		return 0, nil
+	}
+
+	return len(ddl.TableSpec.Columns), nil
+}
+
+// validateInsertQuery checks that an insert query has a placeholder per column of the table. Without
+// db, only queries that the parser understands are checked.
+func validateInsertQuery(db *sql.DB, query string, columns int) error {
+	if db == nil {
+		stmt, err := sqlparser.Parse(query)
+		if err != nil {
+			return nil
+		}
+		if _, ok := stmt.(*sqlparser.Insert); !ok {
+			return errors.New("not an insert statement")
+		}
+		if n := countPlaceholders(stmt); n != columns {
+			return fmt.Errorf("has %d placeholders, table has %d columns", n, columns)
+		}
+		return nil
+	}
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get validation connection: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(dc interface{}) error {
+		stmt, err := dc.(driver.Conn).Prepare(query)
+		if err != nil {
+			return fmt.Errorf("failed to prepare query: %w", err)
+		}
+		defer stmt.Close()
+
+		if n := stmt.NumInput(); n != columns {
+			return fmt.Errorf("has %d placeholders, table has %d columns", n, columns)
+		}
+
+		return nil
+	})
+}
+
+// mappingKey returns the key node of a mapping entry, or the mapping itself if it does not exist.
+func mappingKey(n *yaml.Node, key string) *yaml.Node {
+	if n == nil {
+		return &yaml.Node{}
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i]
+		}
+	}
+
+	return n
+}
+
+// mappingValue returns the value node of a mapping entry, or the mapping itself if it does not
+// exist.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil {
+		return &yaml.Node{}
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+
+	return n
+}
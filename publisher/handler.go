@@ -4,13 +4,19 @@ package publisher
 // Publisher handler implementation.
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff/v3"
+	_ "github.com/go-sql-driver/mysql" // MySQL driver for leases.
 	"github.com/nats-io/stan.go"
 	"github.com/siddontang/go-mysql/canal"
+	"github.com/siddontang/go-mysql/mysql"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	pb "github.com/hojulian/microdb/internal/proto"
 	"github.com/hojulian/microdb/microdb"
@@ -19,25 +25,83 @@ import (
 // Handler represents a data origin publisher.
 type Handler interface {
 	Handle() error
+	// Stop stops publishing row updates and makes Handle return. Unlike Close, the NATS connection
+	// is left open.
+	Stop() error
 	Close() error
+	// Checkpoint returns the binlog position up to which row updates have been published, or an
+	// empty position if the tables were not dumped yet.
+	Checkpoint() mysql.Position
+	// ResumeFrom makes Handle publish the row updates from a checkpoint instead of dumping the
+	// tables first. It must be called before Handle.
+	ResumeFrom(pos mysql.Position)
 }
 
 // MySQLPublisher represents a MySQL-based data origin publisher.
 type MySQLPublisher struct {
 	tableMapping map[string]string
-	c            *canal.Canal
+	cfg          *canal.Config
 	sc           stan.Conn
 
+	// lease is nil if leader election is disabled.
+	lease *Lease
+	ldb   *sql.DB
+
+	mu      sync.Mutex
+	c       *canal.Canal
+	stopped chan struct{}
+	// resume is the checkpoint to publish from, if the tables are not to be dumped.
+	resume mysql.Position
+
 	canal.DummyEventHandler
 }
 
 // Handle starts the event handler for handling new row updates from data origin.
+//
+// With leader election enabled, Handle waits on standby until the publisher holds the lease, and
+// goes back on standby if it loses the lease.
 func (m *MySQLPublisher) Handle() error {
+	if m.lease == nil {
+		return m.run(m.c, m.resume)
+	}
+	defer m.ldb.Close()
+
+	for {
+		pos, acquired, err := m.waitLeader()
+		if err != nil {
+			return err
+		}
+		if m.isStopped() {
+			// The lease may have been acquired right before stopping.
+			return m.lease.Release(mysql.Position{})
+		}
+
+		// Tables that no publisher checkpointed yet are dumped, unless this publisher published
+		// them before.
+		if pos.Name == "" {
+			pos = m.resume
+		}
+		if err := m.lead(pos, acquired); err != nil {
+			return err
+		}
+		if m.isStopped() {
+			return nil
+		}
+	}
+}
+
+func (m *MySQLPublisher) run(c *canal.Canal, pos mysql.Position) error {
 	// Register a handler to handle RowsEvent
-	m.c.SetEventHandler(m)
+	c.SetEventHandler(m)
 
 	err := retry(func() error {
-		if err := m.c.Run(); err != nil {
+		var err error
+		if pos.Name != "" {
+			err = c.RunFrom(pos)
+		} else {
+			err = c.Run()
+		}
+		if err != nil {
 			return fmt.Errorf("canal error: %w", err)
 		}
 		return nil
@@ -49,9 +113,180 @@ func (m *MySQLPublisher) Handle() error {
 	return nil
 }
 
+// waitLeader blocks until the publisher acquires the lease or is stopped, and returns the last
+// checkpointed binlog position along with the time the lease was acquired.
+func (m *MySQLPublisher) waitLeader() (mysql.Position, time.Time, error) {
+	t := time.NewTicker(m.lease.ttl / 3)
+	defer t.Stop()
+
+	for {
+		var pos mysql.Position
+		var acquired time.Time
+		err := retry(func() error {
+			var err error
+			acquired = time.Now()
+			pos, err = m.lease.Acquire()
+			if errors.Is(err, ErrLeaseLost) {
+				return backoff.Permanent(err)
+			}
+			return err
+		})
+		if err == nil {
+			return pos, acquired, nil
+		}
+		if !errors.Is(err, ErrLeaseLost) {
+			return mysql.Position{}, time.Time{}, err
+		}
+
+		select {
+		case <-m.stopped:
+			return mysql.Position{}, time.Time{}, nil
+		case <-t.C:
+		}
+	}
+}
+
+// lead publishes row updates from pos while renewing the lease acquired at the given time. It
+// returns nil once the publisher is stopped or loses the lease.
+func (m *MySQLPublisher) lead(pos mysql.Position, acquired time.Time) error {
+	c, err := newCanal(m.cfg)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if m.isStopped() {
+		m.mu.Unlock()
+		c.Close()
+		return nil
+	}
+	m.c = c
+	m.mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.run(c, pos)
+	}()
+
+	renew := func() error { return m.lease.Renew(checkpoint(c)) }
+	lost, err := keepLease(m.lease.ttl, acquired, renew, done)
+	if !lost {
+		// Hand over to a standby publisher right away, the checkpoint covers every published row
+		// update.
+		if rerr := m.lease.Release(checkpoint(c)); rerr != nil && err == nil {
+			err = rerr
+		}
+		return err
+	}
+
+	m.mu.Lock()
+	m.c = nil
+	m.mu.Unlock()
+
+	c.Close()
+	<-done
+	return nil
+}
+
+// keepLease renews a lease every sixth of its TTL until done receives the result of publishing.
+//
+// It returns true once the lease is lost, or was not renewed for two thirds of its TTL since it was
+// last renewed or acquired. Transient errors are tolerated until then, so that the leader steps
+// down before a standby could acquire the expired lease.
+func keepLease(ttl time.Duration, renewed time.Time, renew func() error, done <-chan error) (bool, error) {
+	t := time.NewTicker(ttl / 6)
+	defer t.Stop()
+
+	margin := ttl * 2 / 3
+	expiry := time.NewTimer(time.Until(renewed.Add(margin)))
+	defer expiry.Stop()
+
+	for {
+		select {
+		case err := <-done:
+			return false, err
+
+		case <-expiry.C:
+			return true, nil
+
+		case <-t.C:
+			now := time.Now()
+			err := renew()
+			switch {
+			case err == nil:
+				renewed = now
+				if !expiry.Stop() {
+					<-expiry.C
+				}
+				expiry.Reset(time.Until(renewed.Add(margin)))
+
+			case errors.Is(err, ErrLeaseLost), time.Since(renewed) >= margin:
+				return true, nil
+			}
+		}
+	}
+}
+
+// checkpoint returns the binlog position up to which row updates have been published, or an empty
+// position while the tables are being dumped.
+func checkpoint(c *canal.Canal) mysql.Position {
+	select {
+	case <-c.WaitDumpDone():
+		return c.SyncedPosition()
+	default:
+		return mysql.Position{}
+	}
+}
+
+// Checkpoint returns the binlog position up to which row updates have been published, or an empty
+// position if the tables were not dumped yet or the publisher is on standby.
+func (m *MySQLPublisher) Checkpoint() mysql.Position {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.c == nil {
+		return mysql.Position{}
+	}
+	return checkpoint(m.c)
+}
+
+// ResumeFrom makes Handle publish the row updates from a checkpoint instead of dumping the tables
+// first, e.g. when restarting a publisher of the same data origin. With leader election, the
+// checkpoint of the lease takes precedence.
+func (m *MySQLPublisher) ResumeFrom(pos mysql.Position) {
+	m.resume = pos
+}
+
+func (m *MySQLPublisher) isStopped() bool {
+	select {
+	case <-m.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stop closes the connection to the data origin.
+func (m *MySQLPublisher) Stop() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.isStopped() {
+		return nil
+	}
+	close(m.stopped)
+
+	if m.c != nil {
+		m.c.Close()
+	}
+	return nil
+}
+
 // Close closes all connections that the handler uses.
 func (m *MySQLPublisher) Close() error {
-	m.c.Close()
+	if err := m.Stop(); err != nil {
+		return err
+	}
 
 	if err := m.sc.Close(); err != nil {
 		return fmt.Errorf("failed to close nats connection: %w", err)
@@ -62,17 +297,19 @@ func (m *MySQLPublisher) Close() error {
 
 // OnRow is a callback that get triggered when a new row update is received from the data origin.
 func (m *MySQLPublisher) OnRow(e *canal.RowsEvent) error {
-	for _, r := range e.Rows {
-		update := &pb.RowUpdate{
-			Row: pb.MarshalCanalValues(e.Table, r),
-		}
+	topic, ok := m.tableMapping[e.Table.Name]
+	if !ok {
+		// Not a table handled by this publisher.
+		return nil
+	}
 
+	for _, update := range rowUpdates(e) {
 		p, err := proto.Marshal(update)
 		if err != nil {
 			return fmt.Errorf("failed to marshal row update: %w", err)
 		}
 
-		if err := m.sc.Publish(m.tableMapping[e.Table.Name], p); err != nil {
+		if err := m.sc.Publish(topic, p); err != nil {
 			return fmt.Errorf("failed to publish row update: %w", err)
 		}
 	}
@@ -80,35 +317,128 @@ func (m *MySQLPublisher) OnRow(e *canal.RowsEvent) error {
 	return nil
 }
 
+// rowUpdates converts a rows event to row updates. The rows of update events come in pairs of the
+// row before and after the update.
+//
+// Row updates of binlog events carry the time they were committed. Rows of the initial dump do not.
+func rowUpdates(e *canal.RowsEvent) []*pb.RowUpdate {
+	var updates []*pb.RowUpdate
+
+	switch e.Action {
+	case canal.UpdateAction:
+		for i := 0; i+1 < len(e.Rows); i += 2 {
+			updates = append(updates, &pb.RowUpdate{
+				Op:     pb.RowOp_ROW_OP_UPDATE,
+				OldRow: pb.MarshalCanalValues(e.Table, e.Rows[i]),
+				Row:    pb.MarshalCanalValues(e.Table, e.Rows[i+1]),
+			})
+		}
+
+	case canal.DeleteAction:
+		for _, r := range e.Rows {
+			updates = append(updates, &pb.RowUpdate{
+				Op:  pb.RowOp_ROW_OP_DELETE,
+				Row: pb.MarshalCanalValues(e.Table, r),
+			})
+		}
+
+	default:
+		for _, r := range e.Rows {
+			updates = append(updates, &pb.RowUpdate{
+				Op:  pb.RowOp_ROW_OP_INSERT,
+				Row: pb.MarshalCanalValues(e.Table, r),
+			})
+		}
+	}
+
+	if e.Header != nil && e.Header.Timestamp != 0 {
+		ts := timestamppb.New(time.Unix(int64(e.Header.Timestamp), 0))
+		for _, u := range updates {
+			u.CommittedAt = ts
+		}
+	}
+
+	return updates
+}
+
 // MySQLHandler returns a new instance of publisher for MySQL-based data origin.
+//
+// Data origins of the tables are looked up in reg.
 func MySQLHandler(host, port, user, password, database string,
-	id uint32, sc stan.Conn, tables ...string) (Handler, error) {
+	id uint32, sc stan.Conn, reg *microdb.Registry, tables ...string) (Handler, error) {
 	cfg := canal.NewDefaultConfig()
 	cfg.Addr = fmt.Sprintf("%s:%s", host, port)
 	cfg.User = user
 	cfg.Password = password
 	cfg.Dump.TableDB = database
-	cfg.Dump.Tables = tables
-	cfg.ServerID = id
 
-	var c *canal.Canal
-	var err error
+	m, err := newMySQLPublisher(cfg, id, sc, reg, tables...)
+	if err != nil {
+		return nil, err
+	}
 
-	rerr := retry(func() error {
-		c, err = canal.NewCanal(cfg)
-		if err != nil {
-			return fmt.Errorf("canal error: %w", err)
+	if m.c, err = newCanal(m.cfg); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// MySQLOriginHandler returns a new instance of publisher for all the given tables of a
+// MySQL-based data origin.
+//
+// The id is used as the replication server ID and must be unique for each publisher of a MySQL
+// server. If lease is not nil, publishers of the same tables elect a leader, see LeaseCfg.
+func MySQLOriginHandler(conn *microdb.ConnectionCfg, id uint32, sc stan.Conn, reg *microdb.Registry,
+	lease *LeaseCfg, tables ...string) (Handler, error) {
+	mc, err := conn.MySQLConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid data origin connection: %w", err)
+	}
+
+	cfg := canal.NewDefaultConfig()
+	cfg.Addr = mc.Addr
+	cfg.User = mc.User
+	cfg.Password = mc.Passwd
+	cfg.Dump.TableDB = mc.DBName
+
+	m, err := newMySQLPublisher(cfg, id, sc, reg, tables...)
+	if err != nil {
+		return nil, err
+	}
+
+	if lease == nil {
+		if m.c, err = newCanal(m.cfg); err != nil {
+			return nil, err
 		}
+		return m, nil
+	}
 
-		return nil
+	// Standby publishers only connect to the data origin for renewing the lease.
+	if m.ldb, err = sql.Open("mysql", conn.Dsn); err != nil {
+		return nil, fmt.Errorf("failed to connect to data origin: %w", err)
+	}
+
+	err = retry(func() error {
+		m.lease, err = NewLease(m.ldb, lease, tables...)
+		return err
 	})
-	if rerr != nil {
-		return nil, fmt.Errorf("failed to create canal client: %w", err)
+	if err != nil {
+		_ = m.ldb.Close()
+		return nil, err
 	}
 
+	return m, nil
+}
+
+func newMySQLPublisher(cfg *canal.Config, id uint32, sc stan.Conn, reg *microdb.Registry,
+	tables ...string) (*MySQLPublisher, error) {
+	cfg.Dump.Tables = tables
+	cfg.ServerID = id
+
 	mapping := make(map[string]string)
 	for _, t := range tables {
-		do, err := microdb.GetDataOrigin(t)
+		do, err := reg.GetDataOrigin(t)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get data origin for table: %w", err)
 		}
@@ -117,11 +447,31 @@ func MySQLHandler(host, port, user, password, database string,
 
 	return &MySQLPublisher{
 		tableMapping: mapping,
-		c:            c,
+		cfg:          cfg,
 		sc:           sc,
+		stopped:      make(chan struct{}),
 	}, nil
 }
 
+func newCanal(cfg *canal.Config) (*canal.Canal, error) {
+	var c *canal.Canal
+	var err error
+
+	rerr := retry(func() error {
+		c, err = canal.NewCanal(cfg)
+		if err != nil {
+			return fmt.Errorf("canal error: %w", err)
+		}
+
+		return nil
+	})
+	if rerr != nil {
+		return nil, fmt.Errorf("failed to create canal client: %w", err)
+	}
+
+	return c, nil
+}
+
 //nolint // Internal method.
 func retry(op func() error) error {
 	bo := backoff.NewExponentialBackOff()
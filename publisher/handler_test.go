@@ -12,6 +12,7 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 	fuzz "github.com/google/gofuzz"
 	"github.com/nats-io/stan.go"
+	"github.com/siddontang/go-mysql/mysql"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/protobuf/proto"
 
@@ -61,7 +62,8 @@ func TestMain(m *testing.M) {
 	}
 
 	// Create publisher
-	pub, err := publisher.MySQLHandler("127.0.0.1", "3306", "root", "test", "test", 1, sc, "test")
+	pub, err := publisher.MySQLHandler("127.0.0.1", "3306", "root", "test", "test", 1, sc,
+		microdb.DefaultRegistry(), "test")
 	if err != nil {
 		log.Fatalf("failed to create publisher: %s", err)
 	}
@@ -156,3 +158,41 @@ func TestHandle(t *testing.T) {
 		})
 	}
 }
+
+func TestLease(t *testing.T) {
+	tables := []string{test.TestTableName, test.UUID()}
+
+	a, err := publisher.NewLease(db, &publisher.LeaseCfg{Holder: "a", TTL: time.Second}, tables...)
+	if err != nil {
+		t.Fatalf("failed to create lease: %s", err)
+	}
+	b, err := publisher.NewLease(db, &publisher.LeaseCfg{Holder: "b", TTL: time.Second}, tables...)
+	if err != nil {
+		t.Fatalf("failed to create lease: %s", err)
+	}
+	pos := mysql.Position{Name: "mysql-bin.000001", Pos: 42}
+
+	// a becomes the leader.
+	got, err := a.Acquire()
+	assert.Nil(t, err)
+	assert.Equal(t, mysql.Position{}, got)
+	_, err = b.Acquire()
+	assert.ErrorIs(t, err, publisher.ErrLeaseLost)
+
+	// a checkpoints a position and hands over to b.
+	assert.Nil(t, a.Renew(pos))
+	assert.Nil(t, a.Release(mysql.Position{}))
+	got, err = b.Acquire()
+	assert.Nil(t, err)
+	assert.Equal(t, pos, got)
+	assert.ErrorIs(t, a.Renew(pos), publisher.ErrLeaseLost)
+
+	// a takes over once b stops renewing.
+	_, err = a.Acquire()
+	assert.ErrorIs(t, err, publisher.ErrLeaseLost)
+	time.Sleep(1500 * time.Millisecond)
+	got, err = a.Acquire()
+	assert.Nil(t, err)
+	assert.Equal(t, pos, got)
+	assert.ErrorIs(t, b.Renew(mysql.Position{}), publisher.ErrLeaseLost)
+}
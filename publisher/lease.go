@@ -0,0 +1,159 @@
+package publisher //nolint // Package comment located in a different file.
+
+import (
+	"context"
+	"crypto/sha1" //nolint // Only used for naming leases.
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/siddontang/go-mysql/mysql"
+)
+
+// Publisher leader election.
+
+// leaseTable is the data origin table holding the leases of publishers.
+const leaseTable = "microdb_publisher_lease"
+
+// ErrLeaseLost is returned when a lease is held by another publisher.
+var ErrLeaseLost = errors.New("lease is held by another publisher")
+
+// LeaseCfg represents the leader election config of a publisher.
+//
+// Publishers of the same tables elect a single leader through a lease stored in the data origin.
+// Only the leader publishes row updates, the others wait on standby and take over at most TTL after
+// the leader stops renewing its lease.
+type LeaseCfg struct {
+	// Holder identifies the publisher, it must be unique for each publisher of the same tables.
+	Holder string
+	// TTL is how long the lease is valid without being renewed.
+	TTL time.Duration
+}
+
+// Lease represents a leadership lease of a set of tables, held in a table of the data origin.
+//
+// Along with the lease, the leader checkpoints the binlog position up to which row updates have
+// been published, so that a new leader resumes from there instead of dumping the tables again.
+type Lease struct {
+	db     *sql.DB
+	name   string
+	holder string
+	ttl    time.Duration
+}
+
+// NewLease returns a lease for the given tables, creating the lease table in the data origin if
+// it does not exist.
+func NewLease(db *sql.DB, cfg *LeaseCfg, tables ...string) (*Lease, error) {
+	if cfg.Holder == "" {
+		return nil, errors.New("empty lease holder")
+	}
+	if cfg.TTL <= 0 {
+		return nil, errors.New("lease ttl must be positive")
+	}
+
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + leaseTable + ` (
+		name VARCHAR(64) NOT NULL PRIMARY KEY,
+		holder VARCHAR(255) NOT NULL,
+		expires_at DATETIME(3) NOT NULL,
+		binlog_file VARCHAR(255) NOT NULL DEFAULT '',
+		binlog_pos INT UNSIGNED NOT NULL DEFAULT 0
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lease table: %w", err)
+	}
+
+	return &Lease{
+		db:     db,
+		name:   leaseName(tables),
+		holder: cfg.Holder,
+		ttl:    cfg.TTL,
+	}, nil
+}
+
+// leaseName identifies a set of tables, independently of their order.
+func leaseName(tables []string) string {
+	ts := append([]string(nil), tables...)
+	sort.Strings(ts)
+
+	return fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(ts, ",")))) //nolint // Not used for security.
+}
+
+// Acquire takes the lease if it is free, expired or already held by the holder, and returns the
+// last checkpointed binlog position. ErrLeaseLost is returned if another publisher holds it.
+//
+// Expiry is evaluated with the clock of the data origin, so publishers need not be in sync.
+func (l *Lease) Acquire() (mysql.Position, error) {
+	// Assignments are evaluated in order, so expires_at is only extended if holder was taken.
+	_, err := l.db.Exec(`INSERT INTO `+leaseTable+` (name, holder, expires_at)
+		VALUES (?, ?, NOW(3) + INTERVAL ? MICROSECOND)
+		ON DUPLICATE KEY UPDATE
+			holder = IF(holder = VALUES(holder) OR expires_at <= NOW(3), VALUES(holder), holder),
+			expires_at = IF(holder = VALUES(holder), VALUES(expires_at), expires_at)`,
+		l.name, l.holder, l.ttl.Microseconds())
+	if err != nil {
+		return mysql.Position{}, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+
+	var holder string
+	var pos mysql.Position
+	err = l.db.QueryRow(`SELECT holder, binlog_file, binlog_pos FROM `+leaseTable+` WHERE name = ?`,
+		l.name).Scan(&holder, &pos.Name, &pos.Pos)
+	if err != nil {
+		return mysql.Position{}, fmt.Errorf("failed to read lease: %w", err)
+	}
+
+	if holder != l.holder {
+		return mysql.Position{}, ErrLeaseLost
+	}
+
+	return pos, nil
+}
+
+// Renew extends the lease and checkpoints the binlog position, an empty position keeps the last
+// checkpoint. ErrLeaseLost is returned if the lease was taken over by another publisher.
+//
+// Renewals taking longer than a sixth of the TTL fail, so that a slow data origin does not keep the
+// leader from stepping down in time.
+func (l *Lease) Renew(pos mysql.Position) error {
+	ctx, cancel := context.WithTimeout(context.Background(), l.ttl/6)
+	defer cancel()
+
+	r, err := l.db.ExecContext(ctx, `UPDATE `+leaseTable+` SET
+			expires_at = NOW(3) + INTERVAL ? MICROSECOND,
+			binlog_file = IF(? = '', binlog_file, ?),
+			binlog_pos = IF(? = '', binlog_pos, ?)
+		WHERE name = ? AND holder = ?`,
+		l.ttl.Microseconds(), pos.Name, pos.Name, pos.Name, pos.Pos, l.name, l.holder)
+	if err != nil {
+		return fmt.Errorf("failed to renew lease: %w", err)
+	}
+
+	ra, err := r.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if ra == 0 {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
+
+// Release checkpoints the binlog position and expires the lease, so that a standby publisher takes
+// over without waiting for the TTL.
+func (l *Lease) Release(pos mysql.Position) error {
+	_, err := l.db.Exec(`UPDATE `+leaseTable+` SET
+			expires_at = NOW(3),
+			binlog_file = IF(? = '', binlog_file, ?),
+			binlog_pos = IF(? = '', binlog_pos, ?)
+		WHERE name = ? AND holder = ?`,
+		pos.Name, pos.Name, pos.Name, pos.Pos, l.name, l.holder)
+	if err != nil {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package publisher
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeepLease(t *testing.T) {
+	ttl := 300 * time.Millisecond
+	errTransient := errors.New("connection refused")
+	errPublish := errors.New("canal error")
+
+	testCases := []struct {
+		desc string
+		// renew returns the result of the nth renewal.
+		renew func(n int) error
+		// done is the result of publishing, sent after doneAfter.
+		done      error
+		doneAfter time.Duration
+		lost      bool
+		err       error
+	}{
+		{
+			desc:      "renewed until stopped",
+			renew:     func(int) error { return nil },
+			doneAfter: 2 * ttl,
+		},
+		{
+			desc:      "publishing failed",
+			renew:     func(int) error { return nil },
+			done:      errPublish,
+			doneAfter: ttl / 2,
+			err:       errPublish,
+		},
+		{
+			desc: "transient renewal failures",
+			renew: func(n int) error {
+				if n <= 2 {
+					return errTransient
+				}
+				return nil
+			},
+			doneAfter: 2 * ttl,
+		},
+		{
+			desc:      "transient renewal failures until expiry",
+			renew:     func(int) error { return errTransient },
+			doneAfter: 2 * ttl,
+			lost:      true,
+		},
+		{
+			desc:      "lease lost",
+			renew:     func(int) error { return ErrLeaseLost },
+			doneAfter: 2 * ttl,
+			lost:      true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			done := make(chan error, 1)
+			timer := time.AfterFunc(tC.doneAfter, func() { done <- tC.done })
+			defer timer.Stop()
+
+			var n int
+			renew := func() error {
+				n++
+				return tC.renew(n)
+			}
+
+			start := time.Now()
+			lost, err := keepLease(ttl, start, renew, done)
+			elapsed := time.Since(start)
+
+			assert.Equal(t, tC.lost, lost)
+			assert.Equal(t, tC.err, err)
+			if lost {
+				// The leader steps down before the lease expires.
+				assert.Less(t, int64(elapsed), int64(ttl*5/6))
+			}
+		})
+	}
+}
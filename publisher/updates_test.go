@@ -0,0 +1,108 @@
+package publisher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/siddontang/go-mysql/canal"
+	"github.com/siddontang/go-mysql/replication"
+	"github.com/siddontang/go-mysql/schema"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+)
+
+func TestRowUpdates(t *testing.T) {
+	table := &schema.Table{
+		Name: "test",
+		Columns: []schema.TableColumn{
+			{Name: "id", Type: schema.TYPE_NUMBER},
+			{Name: "name", Type: schema.TYPE_STRING},
+		},
+	}
+	committedAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	row := func(vs ...interface{}) []*pb.Value { return pb.MarshalCanalValues(table, vs) }
+
+	testCases := []struct {
+		desc   string
+		action string
+		rows   [][]interface{}
+		header *replication.EventHeader
+		want   []*pb.RowUpdate
+	}{
+		{
+			desc:   "insert",
+			action: canal.InsertAction,
+			rows:   [][]interface{}{{int64(1), "a"}, {int64(2), "b"}},
+			header: &replication.EventHeader{Timestamp: uint32(committedAt.Unix())},
+			want: []*pb.RowUpdate{
+				{Op: pb.RowOp_ROW_OP_INSERT, Row: row(int64(1), "a"), CommittedAt: timestamppb.New(committedAt)},
+				{Op: pb.RowOp_ROW_OP_INSERT, Row: row(int64(2), "b"), CommittedAt: timestamppb.New(committedAt)},
+			},
+		},
+		{
+			desc:   "update pairs",
+			action: canal.UpdateAction,
+			rows:   [][]interface{}{{int64(1), "a"}, {int64(1), "b"}, {int64(2), "c"}, {int64(3), "c"}},
+			header: &replication.EventHeader{Timestamp: uint32(committedAt.Unix())},
+			want: []*pb.RowUpdate{
+				{
+					Op:          pb.RowOp_ROW_OP_UPDATE,
+					OldRow:      row(int64(1), "a"),
+					Row:         row(int64(1), "b"),
+					CommittedAt: timestamppb.New(committedAt),
+				},
+				{
+					Op:          pb.RowOp_ROW_OP_UPDATE,
+					OldRow:      row(int64(2), "c"),
+					Row:         row(int64(3), "c"),
+					CommittedAt: timestamppb.New(committedAt),
+				},
+			},
+		},
+		{
+			desc:   "update without row after",
+			action: canal.UpdateAction,
+			rows:   [][]interface{}{{int64(1), "a"}, {int64(1), "b"}, {int64(2), "c"}},
+			want: []*pb.RowUpdate{
+				{Op: pb.RowOp_ROW_OP_UPDATE, OldRow: row(int64(1), "a"), Row: row(int64(1), "b")},
+			},
+		},
+		{
+			desc:   "delete",
+			action: canal.DeleteAction,
+			rows:   [][]interface{}{{int64(1), "a"}},
+			header: &replication.EventHeader{Timestamp: uint32(committedAt.Unix())},
+			want: []*pb.RowUpdate{
+				{Op: pb.RowOp_ROW_OP_DELETE, Row: row(int64(1), "a"), CommittedAt: timestamppb.New(committedAt)},
+			},
+		},
+		{
+			desc:   "dump",
+			action: canal.InsertAction,
+			rows:   [][]interface{}{{int64(1), "a"}},
+			// Rows of the initial dump have no header.
+			want: []*pb.RowUpdate{
+				{Op: pb.RowOp_ROW_OP_INSERT, Row: row(int64(1), "a")},
+			},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got := rowUpdates(&canal.RowsEvent{
+				Table:  table,
+				Action: tC.action,
+				Rows:   tC.rows,
+				Header: tC.header,
+			})
+
+			if assert.Len(t, got, len(tC.want)) {
+				for i := range got {
+					assert.Equal(t, tC.want[i].String(), got[i].String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package querier //nolint // Package comment located in a different file.
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+	"github.com/hojulian/microdb/microdb"
+	mquery "github.com/hojulian/microdb/query"
+)
+
+// Write authorization.
+
+// DefaultMaxClockSkew is the default maximum difference between the time a request was signed and
+// the time it is received.
+const DefaultMaxClockSkew = 5 * time.Minute
+
+// WithClientKeys authenticates requests with the keys of clients, by client ID. Once set, requests
+// that are not signed with the key of a client are rejected.
+func WithClientKeys(keys map[string][]byte) Option {
+	return func(m *MySQLQuerier) {
+		m.keys = keys
+	}
+}
+
+// authorize checks that the client id may execute a request according to the access config of the
+// tables it writes to.
+//
+// Once any table of the registry restricts writes, or client keys are set, every query of a request
+// must parse as a write query.
+func (m *MySQLQuerier) authorize(id string, req *pb.QueryRequest) error {
+	if m.keys == nil && !m.restricted() {
+		return nil
+	}
+
+	if len(req.Statements) == 0 {
+		return m.authorizeQuery(id, req.Query, req.Args)
+	}
+	for i, s := range req.Statements {
+		if err := m.authorizeQuery(id, s.Query, s.Args); err != nil {
+			return &statementError{index: i, err: fmt.Errorf("statement %d: %w", i, err)}
+		}
+	}
+
+	return nil
+}
+
+// authenticate returns the ID of the client of a request, or an empty ID if it is not signed and
+// client keys are not set.
+func (m *MySQLQuerier) authenticate(req *pb.QueryRequest) (string, error) {
+	if req.Auth == nil && m.keys == nil {
+		return "", nil
+	}
+
+	deny := func(err error) (string, error) {
+		return "", &codeError{code: pb.ErrorCode_ERROR_CODE_UNAUTHENTICATED, err: err}
+	}
+	if req.Auth == nil {
+		return deny(errors.New("request is not signed"))
+	}
+
+	id := req.Auth.GetClientId()
+	key, ok := m.keys[id]
+	if !ok {
+		return deny(fmt.Errorf("unknown client %q", id))
+	}
+
+	if err := req.Verify(key, time.Now(), DefaultMaxClockSkew); err != nil {
+		return deny(fmt.Errorf("failed to authenticate client %q: %w", id, err))
+	}
+
+	return id, nil
+}
+
+// authorizeQuery checks that a client may execute a query with args on every table it refers to.
+//
+// On tables that only allow named statements, the query must be the query of one of them, and the
+// args must match the parameters it declares, whether the statement is sent by name or as SQL.
+func (m *MySQLQuerier) authorizeQuery(id, query string, args []*pb.Value) error {
+	q, err := mquery.Query(query)
+	if err != nil {
+		return &codeError{code: pb.ErrorCode_ERROR_CODE_PERMISSION_DENIED, err: err}
+	}
+
+	var op string
+	switch q.GetQueryType() {
+	case mquery.QueryTypeInsert:
+		op = microdb.OperationInsert
+	case mquery.QueryTypeUpdate:
+		op = microdb.OperationUpdate
+	default:
+		return &codeError{
+			code: pb.ErrorCode_ERROR_CODE_PERMISSION_DENIED,
+			err:  errors.New("only insert and update queries are allowed"),
+		}
+	}
+
+	for _, t := range q.GetRequiredTables() {
+		do, err := m.reg.GetDataOrigin(t)
+		if err != nil {
+			return &codeError{
+				code: pb.ErrorCode_ERROR_CODE_PERMISSION_DENIED,
+				err:  fmt.Errorf("unknown table %s", t),
+			}
+		}
+
+		a := do.Access
+		var denied string
+		switch {
+		case !a.AllowsClient(id):
+			denied = fmt.Sprintf("client %q may not write to table %s", id, t)
+		case !a.AllowsOperation(op):
+			denied = fmt.Sprintf("%s queries are not allowed on table %s", op, t)
+		case a != nil && a.NamedOnly:
+			name, ok := do.MatchStatement(query)
+			if !ok {
+				denied = fmt.Sprintf("only named statements are allowed on table %s", t)
+				break
+			}
+			s, err := do.Statement(name)
+			if err == nil {
+				err = s.CheckArgs(pb.UnmarshalValues(args))
+			}
+			if err != nil {
+				denied = fmt.Sprintf("invalid args of statement %s: %s", name, err)
+			}
+		}
+		if denied != "" {
+			return &codeError{code: pb.ErrorCode_ERROR_CODE_PERMISSION_DENIED, err: errors.New(denied)}
+		}
+	}
+
+	return nil
+}
+
+// restricted reports whether any table of the registry restricts writes.
+func (m *MySQLQuerier) restricted() bool {
+	for _, t := range m.reg.Tables() {
+		if do, err := m.reg.GetDataOrigin(t); err == nil && do.Access != nil {
+			return true
+		}
+	}
+	return false
+}
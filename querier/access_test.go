@@ -0,0 +1,102 @@
+package querier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+	"github.com/hojulian/microdb/microdb"
+)
+
+func TestAuthorizeNamedOnly(t *testing.T) {
+	reg := microdb.NewRegistry()
+	err := reg.AddDataOrigin("test", func() (*microdb.DataOrigin, error) {
+		return &microdb.DataOrigin{
+			Schema: &microdb.Schema{Table: "test"},
+			Access: &microdb.AccessCfg{NamedOnly: true},
+			Statements: map[string]*microdb.NamedStatement{
+				"create_test": {
+					Query:  "INSERT INTO test (id, string_type) VALUES (?, ?)",
+					Params: []string{microdb.ParamInt, microdb.ParamString + "?"},
+				},
+			},
+		}, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to add data origin: %s", err)
+	}
+	m := &MySQLQuerier{reg: reg}
+
+	testCases := []struct {
+		desc    string
+		req     *pb.QueryRequest
+		allowed bool
+	}{
+		{
+			desc: "named statement query",
+			req: &pb.QueryRequest{
+				Query: "INSERT INTO test (id, string_type) VALUES (?, ?)",
+				Args:  pb.MarshalValues([]interface{}{int64(1), "test"}),
+			},
+			allowed: true,
+		},
+		{
+			desc: "named statement query with null arg",
+			req: &pb.QueryRequest{
+				Query: "INSERT INTO test (id, string_type)\n\tVALUES (?, ?)",
+				Args:  pb.MarshalValues([]interface{}{int64(1), nil}),
+			},
+			allowed: true,
+		},
+		{
+			desc: "named statement query with invalid args",
+			req: &pb.QueryRequest{
+				Query: "INSERT INTO test (id, string_type) VALUES (?, ?)",
+				Args:  pb.MarshalValues([]interface{}{"1", "test"}),
+			},
+		},
+		{
+			desc: "named statement query with missing args",
+			req: &pb.QueryRequest{
+				Query: "INSERT INTO test (id, string_type) VALUES (?, ?)",
+				Args:  pb.MarshalValues([]interface{}{int64(1)}),
+			},
+		},
+		{
+			desc: "batch statement with invalid args",
+			req: &pb.QueryRequest{
+				Statements: []*pb.Statement{
+					{
+						Query: "INSERT INTO test (id, string_type) VALUES (?, ?)",
+						Args:  pb.MarshalValues([]interface{}{int64(1), "test"}),
+					},
+					{
+						Query: "INSERT INTO test (id, string_type) VALUES (?, ?)",
+						Args:  pb.MarshalValues([]interface{}{int64(2), int64(2)}),
+					},
+				},
+			},
+		},
+		{
+			desc: "other query",
+			req: &pb.QueryRequest{
+				Query: "INSERT INTO test (id) VALUES (?)",
+				Args:  pb.MarshalValues([]interface{}{int64(1)}),
+			},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			err := m.authorize("", tC.req)
+			if tC.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+				code, _, _ := classify(err)
+				assert.Equal(t, pb.ErrorCode_ERROR_CODE_PERMISSION_DENIED, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package querier //nolint // Package comment located in a different file.
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/hojulian/microdb/internal/logger"
+	pb "github.com/hojulian/microdb/internal/proto"
+)
+
+// Error replies.
+
+// WithErrorHandler sets the function called with errors that could not be replied to the client,
+// e.g. a reply that failed to publish. By default, errors are logged.
+func WithErrorHandler(h func(error)) Option {
+	return func(m *MySQLQuerier) {
+		m.onError = h
+	}
+}
+
+func logError(err error) {
+	logger.Logger("querier").Print(err)
+}
+
+// codeError represents an error replied with a specific error code.
+type codeError struct {
+	code pb.ErrorCode
+	err  error
+}
+
+func (e *codeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codeError) Unwrap() error {
+	return e.err
+}
+
+// statementError represents the failure of a statement of a batch.
+type statementError struct {
+	index int
+	err   error
+}
+
+func (e *statementError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statementError) Unwrap() error {
+	return e.err
+}
+
+// mysqlError represents how a MySQL error number is replied.
+type mysqlError struct {
+	code     pb.ErrorCode
+	sqlstate string
+}
+
+// mysqlErrors maps MySQL error numbers to error codes. The driver does not expose SQLSTATEs, so
+// they are derived from the error number as well.
+//
+//nolint // Used as a lookup table.
+var mysqlErrors = map[uint16]mysqlError{
+	1022: {pb.ErrorCode_ERROR_CODE_DUPLICATE_KEY, "23000"},
+	1048: {pb.ErrorCode_ERROR_CODE_CONSTRAINT_VIOLATION, "23000"},
+	1054: {pb.ErrorCode_ERROR_CODE_SYNTAX, "42S22"},
+	1062: {pb.ErrorCode_ERROR_CODE_DUPLICATE_KEY, "23000"},
+	1064: {pb.ErrorCode_ERROR_CODE_SYNTAX, "42000"},
+	1136: {pb.ErrorCode_ERROR_CODE_SYNTAX, "21S01"},
+	1146: {pb.ErrorCode_ERROR_CODE_SYNTAX, "42S02"},
+	1149: {pb.ErrorCode_ERROR_CODE_SYNTAX, "42000"},
+	1205: {pb.ErrorCode_ERROR_CODE_LOCK_WAIT_TIMEOUT, "HY000"},
+	1213: {pb.ErrorCode_ERROR_CODE_DEADLOCK, "40001"},
+	1216: {pb.ErrorCode_ERROR_CODE_CONSTRAINT_VIOLATION, "23000"},
+	1217: {pb.ErrorCode_ERROR_CODE_CONSTRAINT_VIOLATION, "23000"},
+	1364: {pb.ErrorCode_ERROR_CODE_CONSTRAINT_VIOLATION, "HY000"},
+	1451: {pb.ErrorCode_ERROR_CODE_CONSTRAINT_VIOLATION, "23000"},
+	1452: {pb.ErrorCode_ERROR_CODE_CONSTRAINT_VIOLATION, "23000"},
+	1586: {pb.ErrorCode_ERROR_CODE_DUPLICATE_KEY, "23000"},
+	3819: {pb.ErrorCode_ERROR_CODE_CONSTRAINT_VIOLATION, "HY000"},
+}
+
+// classify returns the error code, and the data origin error number and SQLSTATE if the error
+// comes from the data origin.
+func classify(err error) (pb.ErrorCode, uint32, string) {
+	var ce *codeError
+	if errors.As(err, &ce) {
+		return ce.code, 0, ""
+	}
+
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		if e, ok := mysqlErrors[me.Number]; ok {
+			return e.code, uint32(me.Number), e.sqlstate
+		}
+		return pb.ErrorCode_ERROR_CODE_UNKNOWN, uint32(me.Number), "HY000"
+	}
+
+	var ne net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return pb.ErrorCode_ERROR_CODE_DEADLINE_EXCEEDED, 0, ""
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, mysql.ErrInvalidConn), errors.As(err, &ne):
+		return pb.ErrorCode_ERROR_CODE_UNAVAILABLE, 0, ""
+	}
+
+	return pb.ErrorCode_ERROR_CODE_UNKNOWN, 0, ""
+}
+
+// errorReply returns the marshaled reply of a failed request.
+func errorReply(err error) []byte {
+	code, errno, sqlstate := classify(err)
+	res := &pb.WriteQueryReply{
+		Ok:              false,
+		Msg:             strings.ToValidUTF8(err.Error(), "?"),
+		Code:            code,
+		OriginErrno:     errno,
+		Sqlstate:        sqlstate,
+		FailedStatement: -1,
+	}
+
+	var se *statementError
+	if errors.As(err, &se) {
+		res.FailedStatement = int32(se.index)
+	}
+
+	pm, merr := proto.Marshal(res)
+	if merr != nil {
+		// Only the message could fail to marshal, reply with the code alone.
+		res.Msg = fmt.Sprintf("failed to marshal error reply: %s", merr)
+		pm, _ = proto.Marshal(res)
+	}
+
+	return pm
+}
@@ -2,8 +2,12 @@
 package querier
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff/v3"
@@ -18,56 +22,211 @@ import (
 
 // Querier handler implementation.
 
+// drainTimeout is the maximum time to wait for in-flight requests when stopping a querier.
+const drainTimeout = 30 * time.Second
+
 // Handler represents a data origin querier.
 type Handler interface {
 	Handle() error
+	// AddTable starts handling queries for a table.
+	AddTable(table string) error
+	// RemoveTable stops handling queries for a table, waiting for its in-flight requests.
+	RemoveTable(table string) error
+	// Tables returns the tables handled by the querier.
+	Tables() []string
+	// Stop stops handling new requests and waits for in-flight requests to complete. Unlike Close,
+	// the NATS connection is left open.
+	Stop() error
 	Close() error
 }
 
 // MySQLQuerier represents a MySQL-based data origin querier.
+//
+// A querier handles queries for any number of tables sharing the same data origin connection.
 type MySQLQuerier struct {
-	topic string
-	sc    stan.Conn
-	db    *sql.DB
-	sub   []*nats.Subscription
+	mu       sync.Mutex
+	reg      *microdb.Registry
+	sc       stan.Conn
+	db       *sql.DB
+	handling bool
+	tables   map[string]*workerPool
+
+	workers         int
+	queueSize       int
+	requests        *requestCache
+	persistRequests bool
+	onError         func(error)
+	keys            map[string][]byte
 }
 
 // Handle starts the subscriber for handling write and direct read queries.
+//
+// Any number of queriers may handle the same table, each write is executed by only one of them.
 func (m *MySQLQuerier) Handle() error {
-	wSub, err := m.sc.NatsConn().Subscribe(m.topic, tableWriteHandler(m.sc, m.db))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for t := range m.tables {
+		if err := m.subscribe(t); err != nil {
+			return err
+		}
+	}
+	m.handling = true
+
+	return nil
+}
+
+func (m *MySQLQuerier) subscribe(table string) error {
+	do, err := m.reg.GetDataOrigin(table)
+	if err != nil {
+		return fmt.Errorf("failed to get data origin for table: %w", err)
+	}
+
+	// Queriers of a table share a queue group, so replicas load-balance writes instead of each
+	// executing them.
+	p, err := m.startPool(table, do.WriteTopic(), do.WriteQueueGroup())
 	if err != nil {
 		return fmt.Errorf("failed to subscribe to write query topic: %w", err)
 	}
-	m.sub = append(m.sub, wSub)
+	m.tables[table] = p
 
 	return nil
 }
 
-// Close closes all connections that the handler uses.
-func (m *MySQLQuerier) Close() error {
-	for _, sub := range m.sub {
-		if err := sub.Unsubscribe(); err != nil {
-			return fmt.Errorf("failed to unsubscribe topic: %w", err)
+// AddTable starts handling queries for a table. The table must use the same data origin
+// connection as the querier.
+func (m *MySQLQuerier) AddTable(table string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tables[table]; ok {
+		return nil
+	}
+
+	if !m.handling {
+		m.tables[table] = nil
+		return nil
+	}
+
+	return m.subscribe(table)
+}
+
+// RemoveTable stops handling queries for a table, waiting for its in-flight requests.
+func (m *MySQLQuerier) RemoveTable(table string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.tables[table]
+	if !ok {
+		return nil
+	}
+	delete(m.tables, table)
+
+	if p == nil {
+		return nil
+	}
+
+	if err := p.stop(drainTimeout); err != nil {
+		return fmt.Errorf("failed to drain topic: %w", err)
+	}
+
+	return nil
+}
+
+// Tables returns the tables handled by the querier in sorted order.
+func (m *MySQLQuerier) Tables() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ts := make([]string, 0, len(m.tables))
+	for t := range m.tables {
+		ts = append(ts, t)
+	}
+	sort.Strings(ts)
+
+	return ts
+}
+
+// Stop drains the subscriptions, waiting for in-flight requests, and closes the database
+// connection.
+func (m *MySQLQuerier) Stop() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for t, p := range m.tables {
+		if p == nil {
+			continue
+		}
+
+		if err := p.stop(drainTimeout); err != nil {
+			return fmt.Errorf("failed to drain topic: %w", err)
 		}
+		m.tables[t] = nil
+	}
+	m.handling = false
+
+	if err := m.db.Close(); err != nil {
+		return fmt.Errorf("failed to close database connection: %w", err)
+	}
+
+	return nil
+}
+
+// Close drains the subscriptions, waiting for in-flight requests, and closes all connections that
+// the handler uses.
+func (m *MySQLQuerier) Close() error {
+	if err := m.Stop(); err != nil {
+		return err
 	}
 
 	if err := m.sc.Close(); err != nil {
 		return fmt.Errorf("failed to close nats connection: %w", err)
 	}
 
-	if err := m.db.Close(); err != nil {
-		return fmt.Errorf("failed to close database connection: %w", err)
+	return nil
+}
+
+// drain removes interest in a subscription and waits until all pending messages are handled.
+func drain(sub *nats.Subscription, timeout time.Duration) error {
+	if err := sub.Drain(); err != nil {
+		return fmt.Errorf("failed to drain subscription: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for sub.IsValid() {
+		if time.Now().After(deadline) {
+			return errors.New("timed out waiting for in-flight requests")
+		}
+		time.Sleep(10 * time.Millisecond)
 	}
 
 	return nil
 }
 
 // MySQLHandler returns a new instance of querier for MySQL-based data origin.
-func MySQLHandler(host, port, user, password, database, table string, sc stan.Conn) (Handler, error) {
+//
+// The data origin of the table is looked up in reg.
+func MySQLHandler(host, port, user, password, database, table string, sc stan.Conn,
+	reg *microdb.Registry, opts ...Option) (Handler, error) {
+	return newMySQLQuerier(mySQLDSN(host, port, user, password, database), sc, reg, []string{table}, opts)
+}
+
+// MySQLOriginHandler returns a new instance of querier for all the given tables of a MySQL-based
+// data origin. The tables share a single connection to the data origin.
+func MySQLOriginHandler(conn *microdb.ConnectionCfg, sc stan.Conn, reg *microdb.Registry,
+	tables []string, opts ...Option) (Handler, error) {
+	if _, err := conn.MySQLConfig(); err != nil {
+		return nil, fmt.Errorf("invalid data origin connection: %w", err)
+	}
+
+	return newMySQLQuerier(conn.Dsn, sc, reg, tables, opts)
+}
+
+func newMySQLQuerier(dsn string, sc stan.Conn, reg *microdb.Registry, tables []string,
+	opts []Option) (*MySQLQuerier, error) {
 	var db *sql.DB
 	var err error
 
-	dsn := mySQLDSN(host, port, user, password, database)
 	rerr := retry(func() error {
 		db, err = sql.Open("mysql", dsn)
 		if err != nil {
@@ -79,16 +238,35 @@ func MySQLHandler(host, port, user, password, database, table string, sc stan.Co
 		return nil, fmt.Errorf("failed to connect to data origin: %w", rerr)
 	}
 
-	do, err := microdb.GetDataOrigin(table)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get data origin for table: %w", err)
+	m := &MySQLQuerier{
+		reg:       reg,
+		sc:        sc,
+		db:        db,
+		tables:    make(map[string]*workerPool),
+		workers:   DefaultWorkers,
+		queueSize: DefaultQueueSize,
+		requests:  newRequestCache(DefaultRequestCacheSize, DefaultRequestTTL),
+		onError:   logError,
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
 
-	return &MySQLQuerier{
-		topic: do.WriteTopic(),
-		sc:    sc,
-		db:    db,
-	}, nil
+	if m.persistRequests {
+		if err := retry(func() error { return createRequestTable(db) }); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+	for _, t := range tables {
+		if _, err := reg.GetDataOrigin(t); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("failed to get data origin for table: %w", err)
+		}
+		m.tables[t] = nil
+	}
+
+	return m, nil
 }
 
 func mySQLDSN(host, port, user, password, database string) string {
@@ -103,85 +281,197 @@ func mySQLDSN(host, port, user, password, database string) string {
 	return mCfg.FormatDSN()
 }
 
-func tableWriteHandler(sc stan.Conn, db *sql.DB) func(*nats.Msg) {
-	return func(m *nats.Msg) {
-		var req pb.QueryRequest
+// handleWrite executes a write request received for a table and replies to it. Requests with an ID
+// are executed at most once while their reply is remembered.
+func (m *MySQLQuerier) handleWrite(table string, msg *nats.Msg) {
+	var req pb.QueryRequest
+	if err := proto.Unmarshal(msg.Data, &req); err != nil {
+		m.reply(msg, errorReply(&codeError{
+			code: pb.ErrorCode_ERROR_CODE_INVALID_REQUEST,
+			err:  fmt.Errorf("failed to unmarshal write request: %w", err),
+		}))
+		return
+	}
 
-		if err := proto.Unmarshal(m.Data, &req); err != nil {
-			errMsg := fmt.Errorf("failed to unmarshal write request: %w", err).Error()
-			if rerr := replyError(sc, m, errMsg); rerr != nil {
-				panic(fmt.Errorf("failed to publish error reply: %w: %s", rerr, errMsg))
-			}
-			return
+	// Requests are authorized before their ID is looked up, so that an unauthorized client does
+	// not get the reply of a request it did not send. Signatures cover the request as sent, before
+	// its named statement is resolved.
+	client, err := m.authenticate(&req)
+	if err == nil {
+		err = m.resolveStatement(table, &req)
+	}
+	if err == nil {
+		err = m.authorize(client, &req)
+	}
+	if err != nil {
+		m.reply(msg, errorReply(err))
+		return
+	}
+
+	id := req.GetRequestId()
+	if id == "" {
+		reply, _ := m.write(&req)
+		m.reply(msg, reply)
+		return
+	}
+	if len(id) > maxRequestIDLen {
+		m.reply(msg, errorReply(&codeError{
+			code: pb.ErrorCode_ERROR_CODE_INVALID_REQUEST,
+			err:  fmt.Errorf("request id is longer than %d characters", maxRequestIDLen),
+		}))
+		return
+	}
+
+	e, ok := m.requests.start(id)
+	if !ok {
+		// Retried request, reply with the reply of the first one.
+		<-e.done
+		m.reply(msg, e.reply)
+		return
+	}
+
+	reply, err := m.write(&req)
+	m.requests.finish(e, reply, remembered(err))
+	m.reply(msg, reply)
+}
+
+// write executes a write request and returns its marshaled reply, along with the error it failed
+// with, if any.
+func (m *MySQLQuerier) write(req *pb.QueryRequest) ([]byte, error) {
+	ctx, cancel := requestContext(req)
+	defer cancel()
+
+	if err := ctx.Err(); err != nil {
+		err = fmt.Errorf("request deadline passed before it was executed: %w", err)
+		return errorReply(err), err
+	}
+
+	var reply []byte
+	var err error
+	switch {
+	case m.persistRequests && req.GetRequestId() != "":
+		reply, err = execPersisted(ctx, m.db, req.GetRequestId(), m.requests.ttl,
+			func(tx *sql.Tx) ([]byte, error) {
+				return execRequest(ctx, tx, req)
+			})
+	case len(req.Statements) > 0:
+		reply, err = execTx(ctx, m.db, func(tx *sql.Tx) ([]byte, error) {
+			return execRequest(ctx, tx, req)
+		})
+	default:
+		reply, err = execRequest(ctx, m.db, req)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			// The driver does not always report why the query was interrupted.
+			err = &codeError{code: pb.ErrorCode_ERROR_CODE_DEADLINE_EXCEEDED, err: err}
 		}
+		return errorReply(err), err
+	}
+
+	return reply, nil
+}
+
+// execer represents a database or transaction that executes queries.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
 
-		r, err := db.Exec(req.Query, pb.UnmarshalValues(req.Args)...)
+// execRequest executes the query, or the statements of a batch, of a request and returns its
+// marshaled reply.
+func execRequest(ctx context.Context, e execer, req *pb.QueryRequest) ([]byte, error) {
+	if len(req.Statements) == 0 {
+		r, err := e.ExecContext(ctx, req.Query, pb.UnmarshalValues(req.Args)...)
 		if err != nil {
-			errMsg := fmt.Errorf("failed to execute database query: %w got: %s", err, &req.Args).Error()
-			if rerr := replyError(sc, m, errMsg); rerr != nil {
-				panic(fmt.Errorf("failed to publish error reply: %w: %s", rerr, errMsg))
-			}
-			return
+			return nil, fmt.Errorf("failed to execute database query: %w got: %s", err, &req.Args)
 		}
 
-		ra, err := r.RowsAffected()
+		dr, err := driverResult(r)
 		if err != nil {
-			errMsg := fmt.Errorf("failed to get rows affected: %w", err).Error()
-			if rerr := replyError(sc, m, errMsg); rerr != nil {
-				panic(fmt.Errorf("failed to publish error reply: %w: %s", rerr, errMsg))
-			}
-			return
+			return nil, err
 		}
+		return marshalReply(&pb.WriteQueryReply{Ok: true, Result: dr})
+	}
 
-		lid, err := r.LastInsertId()
+	results := make([]*pb.DriverResult, 0, len(req.Statements))
+	for i, stmt := range req.Statements {
+		r, err := e.ExecContext(ctx, stmt.Query, pb.UnmarshalValues(stmt.Args)...)
 		if err != nil {
-			errMsg := fmt.Errorf("failed to get last insert id: %w", err).Error()
-			if rerr := replyError(sc, m, errMsg); rerr != nil {
-				panic(fmt.Errorf("failed to publish error reply: %w: %s", rerr, errMsg))
+			return nil, &statementError{
+				index: i,
+				err:   fmt.Errorf("failed to execute statement %d: %w got: %s", i, err, &stmt.Args),
 			}
-			return
 		}
 
-		// Reply to request
-		res := &pb.WriteQueryReply{
-			Ok: true,
-			Result: &pb.DriverResult{
-				ResultRowsAffected: ra,
-				ResultLastInsertId: lid,
-			},
-		}
-
-		pm, err := proto.Marshal(res)
+		dr, err := driverResult(r)
 		if err != nil {
-			errMsg := fmt.Errorf("failed to marshal reply: %w", err).Error()
-			if rerr := replyError(sc, m, errMsg); rerr != nil {
-				panic(fmt.Errorf("failed to publish error reply: %w: %s", rerr, errMsg))
-			}
-			return
+			return nil, err
 		}
+		results = append(results, dr)
+	}
 
-		if err := sc.NatsConn().Publish(m.Reply, pm); err != nil {
-			panic(fmt.Errorf("failed to publish error reply: %w", err))
-		}
+	return marshalReply(&pb.WriteQueryReply{Ok: true, Results: results})
+}
+
+// execTx runs exec in a transaction, which is committed if exec succeeds.
+func execTx(ctx context.Context, db *sql.DB, exec func(*sql.Tx) ([]byte, error)) ([]byte, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint // Rolling back a committed transaction is a no-op.
+
+	reply, err := exec(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
+
+	return reply, nil
 }
 
-func replyError(sc stan.Conn, originMsg *nats.Msg, errMsg string) error {
-	res := &pb.WriteQueryReply{
-		Ok:  false,
-		Msg: errMsg,
+func (m *MySQLQuerier) reply(originMsg *nats.Msg, reply []byte) {
+	if err := m.sc.NatsConn().Publish(originMsg.Reply, reply); err != nil {
+		m.onError(fmt.Errorf("failed to publish reply: %w", err))
 	}
+}
 
-	pm, err := proto.Marshal(res)
+func driverResult(r sql.Result) (*pb.DriverResult, error) {
+	ra, err := r.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to marshal error reply: %w", err)
+		return nil, &codeError{
+			code: pb.ErrorCode_ERROR_CODE_INTERNAL,
+			err:  fmt.Errorf("failed to get rows affected: %w", err),
+		}
 	}
 
-	if err := sc.NatsConn().Publish(originMsg.Reply, pm); err != nil {
-		return fmt.Errorf("failed to publish reply: %w", err)
+	lid, err := r.LastInsertId()
+	if err != nil {
+		return nil, &codeError{
+			code: pb.ErrorCode_ERROR_CODE_INTERNAL,
+			err:  fmt.Errorf("failed to get last insert id: %w", err),
+		}
 	}
 
-	return nil
+	return &pb.DriverResult{
+		ResultRowsAffected: ra,
+		ResultLastInsertId: lid,
+	}, nil
+}
+
+func marshalReply(res *pb.WriteQueryReply) ([]byte, error) {
+	pm, err := proto.Marshal(res)
+	if err != nil {
+		return nil, &codeError{
+			code: pb.ErrorCode_ERROR_CODE_INTERNAL,
+			err:  fmt.Errorf("failed to marshal reply: %w", err),
+		}
+	}
+
+	return pm, nil
 }
 
 //nolint // Internal method.
@@ -0,0 +1,129 @@
+package querier //nolint // Package comment located in a different file.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+)
+
+// Per-table worker pools.
+
+const (
+	// DefaultWorkers is the default number of requests of a table executed concurrently.
+	DefaultWorkers = 4
+	// DefaultQueueSize is the default number of requests of a table waiting for a worker.
+	DefaultQueueSize = 64
+)
+
+// WithWorkers sets the number of requests of each table executed concurrently, and how many more
+// could wait for a worker. Requests beyond that are replied to as overloaded, without being
+// executed.
+func WithWorkers(workers, queueSize int) Option {
+	return func(m *MySQLQuerier) {
+		m.workers = workers
+		m.queueSize = queueSize
+	}
+}
+
+// workerPool executes the requests of a table.
+type workerPool struct {
+	sub   *nats.Subscription
+	queue chan *nats.Msg
+	wg    sync.WaitGroup
+	// overloaded replies to the requests that do not fit in the queue.
+	overloaded func(*nats.Msg)
+}
+
+func (m *MySQLQuerier) startPool(table, topic, queue string) (*workerPool, error) {
+	handle := func(msg *nats.Msg) { m.handleWrite(table, msg) }
+	p := newWorkerPool(m.workers, m.queueSize, handle, func(msg *nats.Msg) {
+		m.reply(msg, errorReply(&codeError{
+			code: pb.ErrorCode_ERROR_CODE_OVERLOADED,
+			err:  fmt.Errorf("too many requests for table %s", table),
+		}))
+	})
+
+	sub, err := m.sc.NatsConn().QueueSubscribe(topic, queue, p.push)
+	if err != nil {
+		close(p.queue)
+		return nil, err
+	}
+	p.sub = sub
+
+	return p, nil
+}
+
+// newWorkerPool starts the workers of a pool, executing its requests with handle.
+func newWorkerPool(workers, queueSize int, handle, overloaded func(*nats.Msg)) *workerPool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	p := &workerPool{queue: make(chan *nats.Msg, queueSize), overloaded: overloaded}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for msg := range p.queue {
+				handle(msg)
+			}
+		}()
+	}
+
+	return p
+}
+
+// push queues a request for a worker, or replies to it as overloaded if the queue is full.
+func (p *workerPool) push(msg *nats.Msg) {
+	select {
+	case p.queue <- msg:
+	default:
+		p.overloaded(msg)
+	}
+}
+
+// stop drains the subscription of the pool and waits until all its requests are executed.
+func (p *workerPool) stop(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	if err := drain(p.sub, timeout); err != nil {
+		return err
+	}
+
+	return p.wait(time.Until(deadline))
+}
+
+// wait closes the queue of the pool, once no more requests are pushed, and waits until the queued
+// requests are executed.
+func (p *workerPool) wait(timeout time.Duration) error {
+	close(p.queue)
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return errors.New("timed out waiting for in-flight requests")
+	}
+}
+
+// requestContext returns the context of a request, which is done at its deadline.
+func requestContext(req *pb.QueryRequest) (context.Context, context.CancelFunc) {
+	if req.Deadline == nil {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithDeadline(context.Background(), req.Deadline.AsTime())
+}
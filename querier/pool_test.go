@@ -0,0 +1,123 @@
+package querier
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+)
+
+// testPool is a worker pool whose requests block until released.
+type testPool struct {
+	*workerPool
+
+	release chan struct{}
+	started chan string
+
+	mu         sync.Mutex
+	handled    []string
+	overloaded []string
+}
+
+func newTestPool(workers, queueSize int) *testPool {
+	tp := &testPool{release: make(chan struct{}), started: make(chan string, 16)}
+	tp.workerPool = newWorkerPool(workers, queueSize, func(msg *nats.Msg) {
+		tp.started <- msg.Subject
+		<-tp.release
+
+		tp.mu.Lock()
+		defer tp.mu.Unlock()
+		tp.handled = append(tp.handled, msg.Subject)
+	}, func(msg *nats.Msg) {
+		tp.mu.Lock()
+		defer tp.mu.Unlock()
+		tp.overloaded = append(tp.overloaded, msg.Subject)
+	})
+
+	return tp
+}
+
+func (tp *testPool) results() ([]string, []string) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	return append([]string(nil), tp.handled...), append([]string(nil), tp.overloaded...)
+}
+
+func TestWorkerPoolOverloaded(t *testing.T) {
+	tp := newTestPool(1, 1)
+
+	// The first request is executed, the second one queued and the third one rejected.
+	tp.push(&nats.Msg{Subject: "1"})
+	assert.Equal(t, "1", <-tp.started)
+	tp.push(&nats.Msg{Subject: "2"})
+	tp.push(&nats.Msg{Subject: "3"})
+
+	handled, overloaded := tp.results()
+	assert.Empty(t, handled)
+	assert.Equal(t, []string{"3"}, overloaded)
+
+	close(tp.release)
+	assert.Nil(t, tp.wait(time.Second))
+
+	handled, overloaded = tp.results()
+	assert.Equal(t, []string{"1", "2"}, handled)
+	assert.Equal(t, []string{"3"}, overloaded)
+}
+
+func TestWorkerPoolWait(t *testing.T) {
+	tp := newTestPool(2, 4)
+
+	for _, s := range []string{"1", "2", "3", "4"} {
+		tp.push(&nats.Msg{Subject: s})
+	}
+	<-tp.started
+	<-tp.started
+
+	// In-flight and queued requests are executed before the pool is stopped.
+	waited := make(chan error, 1)
+	go func() {
+		waited <- tp.wait(time.Second)
+	}()
+
+	select {
+	case <-waited:
+		t.Fatalf("pool stopped before its requests were executed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(tp.release)
+	assert.Nil(t, <-waited)
+
+	handled, overloaded := tp.results()
+	assert.ElementsMatch(t, []string{"1", "2", "3", "4"}, handled)
+	assert.Empty(t, overloaded)
+}
+
+func TestWorkerPoolWaitTimeout(t *testing.T) {
+	tp := newTestPool(1, 1)
+	defer close(tp.release)
+
+	tp.push(&nats.Msg{Subject: "1"})
+	<-tp.started
+
+	assert.EqualError(t, tp.wait(50*time.Millisecond), "timed out waiting for in-flight requests")
+}
+
+func TestRequestContext(t *testing.T) {
+	ctx, cancel := requestContext(&pb.QueryRequest{})
+	defer cancel()
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+
+	deadline := time.Now().Add(time.Minute)
+	ctx, cancel = requestContext(&pb.QueryRequest{Deadline: timestamppb.New(deadline)})
+	defer cancel()
+	d, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.True(t, d.Equal(deadline.Round(0)), "got deadline %s", d)
+}
@@ -0,0 +1,216 @@
+package querier //nolint // Package comment located in a different file.
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+)
+
+// Idempotent write requests.
+
+const (
+	// DefaultRequestCacheSize is the default number of request replies remembered by a querier.
+	DefaultRequestCacheSize = 10000
+	// DefaultRequestTTL is the default duration request replies are remembered for.
+	DefaultRequestTTL = 10 * time.Minute
+
+	// requestTable is the data origin table holding the replies of persisted requests.
+	requestTable = "microdb_write_request"
+	// maxRequestIDLen is the maximum length of request IDs.
+	maxRequestIDLen = 64
+	// requestCleanupLimit is the maximum number of expired requests deleted per write.
+	requestCleanupLimit = 100
+	// mysqlErrDupEntry is the MySQL error number of duplicate key violations.
+	mysqlErrDupEntry = 1062
+)
+
+// Option represents a querier option.
+type Option func(*MySQLQuerier)
+
+// WithRequestCache sets the number of request replies a querier remembers, and for how long.
+//
+// A request sent again with the same ID within ttl is replied to with the remembered reply instead
+// of being executed again. A size of 0 disables the cache.
+func WithRequestCache(size int, ttl time.Duration) Option {
+	return func(m *MySQLQuerier) {
+		m.requests = newRequestCache(size, ttl)
+	}
+}
+
+// WithPersistedRequests records the replies of requests in the data origin, in the same transaction
+// as the write, for the duration of the request cache TTL.
+//
+// Unlike the request cache, this makes requests idempotent across queriers of the same tables.
+func WithPersistedRequests() Option {
+	return func(m *MySQLQuerier) {
+		m.persistRequests = true
+	}
+}
+
+// requestCache remembers the replies of the most recent requests.
+type requestCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type requestEntry struct {
+	id    string
+	done  chan struct{}
+	reply []byte
+	// expires is zero until the request is finished.
+	expires time.Time
+}
+
+func newRequestCache(size int, ttl time.Duration) *requestCache {
+	return &requestCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// start returns the entry of a request, and whether the request is new. If it is, the caller must
+// call finish with its reply. Otherwise, the reply is available once the entry is done.
+func (c *requestCache) start(id string) (*requestEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[id]; ok {
+		e := el.Value.(*requestEntry) //nolint // Only entries are stored.
+		if e.expires.IsZero() || time.Now().Before(e.expires) {
+			return e, false
+		}
+		c.remove(el)
+	}
+
+	e := &requestEntry{id: id, done: make(chan struct{})}
+	if c.size <= 0 {
+		return e, true
+	}
+
+	c.entries[id] = c.order.PushFront(e)
+	c.evict()
+
+	return e, true
+}
+
+// evict forgets the oldest finished requests while the cache is over size. Requests still running
+// are kept, so that a retry during the write waits for it rather than executing it again, and the
+// cache goes over size until they finish.
+func (c *requestCache) evict() {
+	for el := c.order.Back(); el != nil && c.order.Len() > c.size; {
+		prev := el.Prev()
+		if !el.Value.(*requestEntry).expires.IsZero() { //nolint // Only entries are stored.
+			c.remove(el)
+		}
+		el = prev
+	}
+}
+
+// finish sets the reply of a new request, and replies to the requests with the same ID waiting for
+// it. Unless keep is set, the request is forgotten and executed again if retried.
+func (c *requestCache) finish(e *requestEntry, reply []byte, keep bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e.reply = reply
+	e.expires = time.Now().Add(c.ttl)
+	close(e.done)
+
+	if keep {
+		c.evict()
+		return
+	}
+	if el, ok := c.entries[e.id]; ok && el.Value.(*requestEntry) == e { //nolint // Only entries are stored.
+		c.remove(el)
+	}
+}
+
+// remembered reports whether the reply of a request that failed with err, if any, is remembered.
+// Like persisted requests, whose transaction is rolled back, requests that failed with a retryable
+// error or past their deadline are executed again if retried.
+func remembered(err error) bool {
+	if err == nil {
+		return true
+	}
+
+	switch code, _, _ := classify(err); code {
+	case pb.ErrorCode_ERROR_CODE_DEADLOCK,
+		pb.ErrorCode_ERROR_CODE_LOCK_WAIT_TIMEOUT,
+		pb.ErrorCode_ERROR_CODE_UNAVAILABLE,
+		pb.ErrorCode_ERROR_CODE_OVERLOADED,
+		pb.ErrorCode_ERROR_CODE_DEADLINE_EXCEEDED:
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *requestCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*requestEntry).id) //nolint // Only entries are stored.
+}
+
+func createRequestTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + requestTable + ` (
+		request_id VARCHAR(64) NOT NULL PRIMARY KEY,
+		reply BLOB,
+		created_at DATETIME(3) NOT NULL,
+		KEY (created_at)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create request table: %w", err)
+	}
+
+	return nil
+}
+
+// execPersisted executes a write request and records its reply in the same transaction. If the
+// request was already executed, its recorded reply is returned instead and exec is not called.
+func execPersisted(ctx context.Context, db *sql.DB, id string, ttl time.Duration,
+	exec func(*sql.Tx) ([]byte, error)) ([]byte, error) {
+	// Expired requests are cleaned up a few at a time, best effort.
+	_, _ = db.ExecContext(ctx, `DELETE FROM `+requestTable+
+		` WHERE created_at < NOW(3) - INTERVAL ? MICROSECOND LIMIT ?`, ttl.Microseconds(), requestCleanupLimit)
+
+	return execTx(ctx, db, func(tx *sql.Tx) ([]byte, error) {
+		// A concurrent request with the same ID blocks here until the first one commits.
+		_, err := tx.ExecContext(ctx, `INSERT INTO `+requestTable+` (request_id, created_at) VALUES (?, NOW(3))`, id)
+		var merr *mysql.MySQLError
+		if errors.As(err, &merr) && merr.Number == mysqlErrDupEntry {
+			var reply []byte
+			if err := db.QueryRowContext(ctx,
+				`SELECT reply FROM `+requestTable+` WHERE request_id = ?`, id).Scan(&reply); err != nil {
+				return nil, fmt.Errorf("failed to read request reply: %w", err)
+			}
+			return reply, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to record request: %w", err)
+		}
+
+		reply, err := exec(tx)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE `+requestTable+` SET reply = ? WHERE request_id = ?`, reply, id); err != nil {
+			return nil, fmt.Errorf("failed to record request reply: %w", err)
+		}
+
+		return reply, nil
+	})
+}
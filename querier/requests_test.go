@@ -0,0 +1,135 @@
+package querier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestCache(t *testing.T) {
+	testCases := []struct {
+		desc string
+		err  error
+		// retried is whether a request with the same ID is executed again.
+		retried bool
+	}{
+		{
+			desc: "succeeded",
+		},
+		{
+			desc: "duplicate key",
+			err:  fmt.Errorf("failed to execute database query: %w", &mysql.MySQLError{Number: 1062}),
+		},
+		{
+			desc: "syntax error",
+			err:  &mysql.MySQLError{Number: 1064},
+		},
+		{
+			desc:    "deadlock",
+			err:     fmt.Errorf("failed to execute database query: %w", &mysql.MySQLError{Number: 1213}),
+			retried: true,
+		},
+		{
+			desc:    "lock wait timeout",
+			err:     &mysql.MySQLError{Number: 1205},
+			retried: true,
+		},
+		{
+			desc:    "unavailable",
+			err:     fmt.Errorf("failed to execute database query: %w", mysql.ErrInvalidConn),
+			retried: true,
+		},
+		{
+			desc:    "deadline exceeded",
+			err:     fmt.Errorf("request deadline passed before it was executed: %w", context.DeadlineExceeded),
+			retried: true,
+		},
+		{
+			desc: "unknown",
+			err:  errors.New("unknown error"),
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			c := newRequestCache(10, time.Minute)
+
+			e, ok := c.start("request")
+			assert.True(t, ok)
+
+			reply := []byte("reply")
+			c.finish(e, reply, remembered(tC.err))
+
+			// Requests waiting for the first one get its reply either way.
+			<-e.done
+			assert.Equal(t, reply, e.reply)
+
+			re, ok := c.start("request")
+			assert.Equal(t, tC.retried, ok)
+			if !tC.retried {
+				assert.Equal(t, reply, re.reply)
+			}
+		})
+	}
+}
+
+func TestRequestCacheEviction(t *testing.T) {
+	c := newRequestCache(2, 50*time.Millisecond)
+
+	for _, id := range []string{"a", "b", "c"} {
+		e, ok := c.start(id)
+		assert.True(t, ok)
+		c.finish(e, []byte(id), true)
+	}
+
+	// The oldest request is evicted once the cache is full.
+	_, ok := c.start("a")
+	assert.True(t, ok)
+	_, ok = c.start("c")
+	assert.False(t, ok)
+
+	// Replies expire after the TTL.
+	time.Sleep(100 * time.Millisecond)
+	_, ok = c.start("c")
+	assert.True(t, ok)
+}
+
+func TestRequestCacheEvictionRunning(t *testing.T) {
+	c := newRequestCache(2, time.Minute)
+
+	running, ok := c.start("running")
+	assert.True(t, ok)
+	for _, id := range []string{"a", "b", "c"} {
+		e, ok := c.start(id)
+		assert.True(t, ok)
+		c.finish(e, []byte(id), true)
+	}
+
+	// The oldest finished requests are evicted, rather than the running one.
+	retry, ok := c.start("running")
+	assert.False(t, ok)
+	assert.Equal(t, running, retry)
+	_, ok = c.start("c")
+	assert.False(t, ok)
+	_, ok = c.start("a")
+	assert.True(t, ok)
+
+	// With only running requests, the cache goes over size until they finish.
+	more, ok := c.start("more")
+	assert.True(t, ok)
+	assert.Equal(t, 3, c.order.Len())
+	_, ok = c.start("more")
+	assert.False(t, ok)
+
+	c.finish(running, []byte("running"), true)
+	<-retry.done
+	assert.Equal(t, []byte("running"), retry.reply)
+	assert.Equal(t, 2, c.order.Len())
+	c.finish(more, []byte("more"), true)
+	assert.Equal(t, 2, c.order.Len())
+}
@@ -0,0 +1,46 @@
+package querier //nolint // Package comment located in a different file.
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+)
+
+// Named statements.
+
+// resolveStatement sets the query of a request received for a table to the named statement of the
+// table, after checking its args against the declared parameters of the statement. Requests for
+// the statements of other tables are rejected, as they would be executed on the data origin of the
+// table they were received for.
+func (m *MySQLQuerier) resolveStatement(table string, req *pb.QueryRequest) error {
+	invalid := func(err error) error {
+		return &codeError{code: pb.ErrorCode_ERROR_CODE_INVALID_REQUEST, err: err}
+	}
+	if (req.GetStatement() != "" || req.GetTable() != "") && req.GetTable() != table {
+		return invalid(fmt.Errorf("request for table %q received for table %s", req.GetTable(), table))
+	}
+	if req.GetStatement() == "" {
+		return nil
+	}
+	if req.GetQuery() != "" || len(req.GetStatements()) > 0 {
+		return invalid(errors.New("request has both a named statement and a query"))
+	}
+
+	do, err := m.reg.GetDataOrigin(table)
+	if err != nil {
+		return invalid(fmt.Errorf("unknown table %s", table))
+	}
+
+	s, err := do.Statement(req.GetStatement())
+	if err != nil {
+		return invalid(err)
+	}
+
+	if err := s.CheckArgs(pb.UnmarshalValues(req.GetArgs())); err != nil {
+		return invalid(fmt.Errorf("invalid args of statement %s: %w", req.GetStatement(), err))
+	}
+	req.Query = s.Query
+
+	return nil
+}
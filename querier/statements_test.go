@@ -0,0 +1,107 @@
+package querier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pb "github.com/hojulian/microdb/internal/proto"
+	"github.com/hojulian/microdb/microdb"
+)
+
+func TestResolveStatement(t *testing.T) {
+	reg := microdb.NewRegistry()
+	for _, table := range []string{"test", "other"} {
+		table := table
+		err := reg.AddDataOrigin(table, func() (*microdb.DataOrigin, error) {
+			return &microdb.DataOrigin{
+				Schema: &microdb.Schema{Table: table},
+				Statements: map[string]*microdb.NamedStatement{
+					"create_" + table: {
+						Query:  "INSERT INTO " + table + " (id) VALUES (?)",
+						Params: []string{microdb.ParamInt},
+					},
+				},
+			}, nil
+		})
+		if err != nil {
+			t.Fatalf("failed to add data origin: %s", err)
+		}
+	}
+	m := &MySQLQuerier{reg: reg}
+
+	testCases := []struct {
+		desc  string
+		req   *pb.QueryRequest
+		query string
+		// valid is whether the request is accepted by the querier of table test.
+		valid bool
+	}{
+		{
+			desc: "named statement",
+			req: &pb.QueryRequest{
+				Table:     "test",
+				Statement: "create_test",
+				Args:      pb.MarshalValues([]interface{}{int64(1)}),
+			},
+			query: "INSERT INTO test (id) VALUES (?)",
+			valid: true,
+		},
+		{
+			desc:  "query",
+			req:   &pb.QueryRequest{Query: "INSERT INTO test (id) VALUES (1)"},
+			query: "INSERT INTO test (id) VALUES (1)",
+			valid: true,
+		},
+		{
+			desc: "named statement of other table",
+			req: &pb.QueryRequest{
+				Table:     "other",
+				Statement: "create_other",
+				Args:      pb.MarshalValues([]interface{}{int64(1)}),
+			},
+		},
+		{
+			desc: "named statement of other table in table",
+			req: &pb.QueryRequest{
+				Table:     "test",
+				Statement: "create_other",
+				Args:      pb.MarshalValues([]interface{}{int64(1)}),
+			},
+		},
+		{
+			desc: "named statement without table",
+			req: &pb.QueryRequest{
+				Statement: "create_test",
+				Args:      pb.MarshalValues([]interface{}{int64(1)}),
+			},
+		},
+		{
+			desc: "query for other table",
+			req:  &pb.QueryRequest{Table: "other", Query: "INSERT INTO test (id) VALUES (1)"},
+		},
+		{
+			desc: "named statement with invalid args",
+			req: &pb.QueryRequest{
+				Table:     "test",
+				Statement: "create_test",
+				Args:      pb.MarshalValues([]interface{}{"1"}),
+			},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			err := m.resolveStatement("test", tC.req)
+			if !tC.valid {
+				code, _, _ := classify(err)
+				assert.Equal(t, pb.ErrorCode_ERROR_CODE_INVALID_REQUEST, code)
+				return
+			}
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, tC.query, tC.req.GetQuery())
+			}
+		})
+	}
+}
@@ -0,0 +1,486 @@
+package query //nolint // Package comment located in a different file.
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cube2222/octosql/parser/sqlparser"
+)
+
+// Row filters.
+
+// Filter represents a boolean SQL expression over the columns of a row, e.g. "tenant_id = 42".
+//
+// Comparisons, IN, BETWEEN, IS [NOT] NULL, AND, OR and NOT are supported, with column names and
+// literal values as operands. As in SQL, comparisons with NULL are neither true nor false, and rows
+// only match if the expression is true.
+type Filter struct {
+	expr    string
+	where   sqlparser.Expr
+	columns []string
+}
+
+// ParseFilter parses a filter expression.
+func ParseFilter(expr string) (*Filter, error) {
+	stmt, err := sqlparser.Parse("SELECT * FROM t WHERE " + expr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse filter with sqlparser: %w", err)
+	}
+
+	s, ok := stmt.(*sqlparser.Select)
+	if !ok || s.Where == nil || s.Limit != nil || len(s.OrderBy) > 0 || len(s.GroupBy) > 0 {
+		return nil, errors.New("not a boolean expression")
+	}
+
+	f := &Filter{expr: expr, where: s.Where.Expr}
+	seen := make(map[string]bool)
+	if err := sqlparser.Walk(func(n sqlparser.SQLNode) (bool, error) {
+		switch n := n.(type) {
+		case *sqlparser.ColName:
+			if name := n.Name.Lowered(); !seen[name] {
+				seen[name] = true
+				f.columns = append(f.columns, name)
+			}
+		case *sqlparser.Subquery, *sqlparser.FuncExpr:
+			return false, errors.New("subqueries and functions are not supported")
+		}
+		return true, nil
+	}, f.where); err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+
+	// Evaluating the filter on an empty row reports unsupported expressions early.
+	if _, err := f.eval(f.where, nil); err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+
+	return f, nil
+}
+
+// String returns the filter expression.
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// Columns returns the names of the columns the filter refers to, lowercased.
+func (f *Filter) Columns() []string {
+	return f.columns
+}
+
+// Match reports whether a row, by lowercased column name, matches the filter.
+func (f *Filter) Match(row map[string]interface{}) (bool, error) {
+	v, err := f.eval(f.where, row)
+	if err != nil {
+		return false, err
+	}
+
+	b, _ := v.(bool)
+	return b, nil
+}
+
+// Covers reports whether the results of a select query only depend on rows matching the filter,
+// i.e. it reads a single table and its WHERE clause includes every condition of the filter, joined
+// with AND. Conditions are compared as written, ignoring parentheses and table qualifiers of
+// columns.
+func (f *Filter) Covers(q *QueryStmt) bool {
+	s, ok := q.stmt.(*sqlparser.Select)
+	if !ok || s.Where == nil || len(s.From) != 1 {
+		return false
+	}
+	if t, ok := s.From[0].(*sqlparser.AliasedTableExpr); !ok {
+		return false
+	} else if _, ok := t.Expr.(sqlparser.TableName); !ok {
+		return false
+	}
+
+	conds := make(map[string]bool)
+	for _, c := range conjuncts(s.Where.Expr, nil) {
+		conds[unqualified(c)] = true
+	}
+	for _, c := range conjuncts(f.where, nil) {
+		if !conds[unqualified(c)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// conjuncts returns the conditions of an expression joined with AND.
+func conjuncts(expr sqlparser.Expr, cs []sqlparser.Expr) []sqlparser.Expr {
+	switch e := expr.(type) {
+	case *sqlparser.AndExpr:
+		return conjuncts(e.Right, conjuncts(e.Left, cs))
+	case *sqlparser.ParenExpr:
+		return conjuncts(e.Expr, cs)
+	}
+	return append(cs, expr)
+}
+
+// unqualified formats an expression with lowercased column names, without table qualifiers.
+func unqualified(expr sqlparser.Expr) string {
+	buf := sqlparser.NewTrackedBuffer(func(buf *sqlparser.TrackedBuffer, n sqlparser.SQLNode) {
+		if c, ok := n.(*sqlparser.ColName); ok {
+			buf.WriteString(c.Name.Lowered())
+			return
+		}
+		n.Format(buf)
+	})
+	buf.Myprintf("%v", expr)
+	return buf.String()
+}
+
+// eval evaluates an expression. Boolean expressions evaluate to true, false or nil if unknown.
+//nolint // Allow eval method to exceed suggested method size.
+func (f *Filter) eval(expr sqlparser.Expr, row map[string]interface{}) (interface{}, error) {
+	switch e := expr.(type) {
+	case *sqlparser.ParenExpr:
+		return f.eval(e.Expr, row)
+
+	case *sqlparser.AndExpr, *sqlparser.OrExpr:
+		var l, r sqlparser.Expr
+		and := false
+		if a, ok := e.(*sqlparser.AndExpr); ok {
+			l, r, and = a.Left, a.Right, true
+		} else {
+			o := e.(*sqlparser.OrExpr) //nolint // Checked by the case.
+			l, r = o.Left, o.Right
+		}
+
+		lv, err := f.evalBool(l, row)
+		if err != nil {
+			return nil, err
+		}
+		rv, err := f.evalBool(r, row)
+		if err != nil {
+			return nil, err
+		}
+
+		// A false operand of AND, or a true operand of OR, decides the result even if the other is
+		// unknown.
+		if lv == !and || rv == !and {
+			return !and, nil
+		}
+		if lv == nil || rv == nil {
+			return nil, nil
+		}
+		return and, nil
+
+	case *sqlparser.NotExpr:
+		v, err := f.evalBool(e.Expr, row)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		return !v.(bool), nil //nolint // evalBool returns booleans or nil.
+
+	case *sqlparser.IsExpr:
+		v, err := f.eval(e.Expr, row)
+		if err != nil {
+			return nil, err
+		}
+		switch e.Operator {
+		case sqlparser.IsNullStr:
+			return v == nil, nil
+		case sqlparser.IsNotNullStr:
+			return v != nil, nil
+		}
+
+		b, ok := truth(v)
+		switch e.Operator {
+		case sqlparser.IsTrueStr:
+			return ok && b, nil
+		case sqlparser.IsNotTrueStr:
+			return !(ok && b), nil
+		case sqlparser.IsFalseStr:
+			return ok && !b, nil
+		case sqlparser.IsNotFalseStr:
+			return !(ok && !b), nil
+		}
+		return nil, fmt.Errorf("unsupported operator %q", e.Operator)
+
+	case *sqlparser.ComparisonExpr:
+		l, err := f.eval(e.Left, row)
+		if err != nil {
+			return nil, err
+		}
+
+		switch e.Operator {
+		case sqlparser.InStr, sqlparser.NotInStr:
+			tuple, ok := e.Right.(sqlparser.ValTuple)
+			if !ok {
+				return nil, errors.New("in requires a list of values")
+			}
+			var res interface{} = false
+			for _, te := range tuple {
+				r, err := f.eval(te, row)
+				if err != nil {
+					return nil, err
+				}
+				c, ok := compare(l, r)
+				if !ok {
+					res = nil
+					continue
+				}
+				if c == 0 {
+					res = true
+					break
+				}
+			}
+			if b, ok := res.(bool); ok && e.Operator == sqlparser.NotInStr {
+				return !b, nil
+			}
+			return res, nil
+		}
+
+		r, err := f.eval(e.Right, row)
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Operator == sqlparser.NullSafeEqualStr {
+			if l == nil || r == nil {
+				return l == nil && r == nil, nil
+			}
+		}
+
+		c, ok := compare(l, r)
+		if !ok {
+			if l == nil || r == nil {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("cannot compare %T with %T", l, r)
+		}
+
+		switch e.Operator {
+		case sqlparser.EqualStr, sqlparser.NullSafeEqualStr:
+			return c == 0, nil
+		case sqlparser.NotEqualStr:
+			return c != 0, nil
+		case sqlparser.LessThanStr:
+			return c < 0, nil
+		case sqlparser.LessEqualStr:
+			return c <= 0, nil
+		case sqlparser.GreaterThanStr:
+			return c > 0, nil
+		case sqlparser.GreaterEqualStr:
+			return c >= 0, nil
+		}
+		return nil, fmt.Errorf("unsupported operator %q", e.Operator)
+
+	case *sqlparser.RangeCond:
+		v, err := f.eval(e.Left, row)
+		if err != nil {
+			return nil, err
+		}
+		from, err := f.eval(e.From, row)
+		if err != nil {
+			return nil, err
+		}
+		to, err := f.eval(e.To, row)
+		if err != nil {
+			return nil, err
+		}
+
+		cf, okf := compare(v, from)
+		ct, okt := compare(v, to)
+		if !okf || !okt {
+			return nil, nil
+		}
+		in := cf >= 0 && ct <= 0
+		if e.Operator == sqlparser.NotBetweenStr {
+			return !in, nil
+		}
+		return in, nil
+
+	case *sqlparser.ColName:
+		v, ok := row[e.Name.Lowered()]
+		if !ok && row != nil {
+			return nil, fmt.Errorf("unknown column %s", e.Name.String())
+		}
+		return normalize(v), nil
+
+	case *sqlparser.SQLVal:
+		switch e.Type {
+		case sqlparser.StrVal:
+			return string(e.Val), nil
+		case sqlparser.IntVal:
+			return strconv.ParseInt(string(e.Val), 10, 64) //nolint // Validated by the parser.
+		case sqlparser.FloatVal:
+			return strconv.ParseFloat(string(e.Val), 64) //nolint // Validated by the parser.
+		}
+		return nil, errors.New("unsupported literal")
+
+	case *sqlparser.NullVal:
+		return nil, nil
+
+	case sqlparser.BoolVal:
+		return bool(e), nil
+
+	case *sqlparser.UnaryExpr:
+		v, err := f.eval(e.Expr, row)
+		if err != nil {
+			return nil, err
+		}
+		if e.Operator == sqlparser.UMinusStr {
+			switch n := v.(type) {
+			case nil:
+				return nil, nil
+			case int64:
+				return -n, nil
+			case float64:
+				return -n, nil
+			}
+		}
+		return nil, fmt.Errorf("unsupported operator %q", e.Operator)
+	}
+
+	return nil, fmt.Errorf("unsupported expression %s", sqlparser.String(expr))
+}
+
+// evalBool evaluates a boolean expression to true, false or nil if unknown.
+func (f *Filter) evalBool(expr sqlparser.Expr, row map[string]interface{}) (interface{}, error) {
+	v, err := f.eval(expr, row)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	b, ok := truth(v)
+	if !ok {
+		return nil, fmt.Errorf("not a boolean expression: %s", sqlparser.String(expr))
+	}
+	return b, nil
+}
+
+// truth converts a value to a boolean, as MySQL does with numbers.
+func truth(v interface{}) (bool, bool) {
+	switch v := v.(type) {
+	case bool:
+		return v, true
+	case int64:
+		return v != 0, true
+	case float64:
+		return v != 0, true
+	}
+	return false, false
+}
+
+// normalize converts row values to int64, float64, string, bool or time.Time.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case []byte:
+		return string(v)
+	}
+	return v
+}
+
+// timeLayouts are the layouts of times compared to strings.
+//nolint // Used as a lookup table.
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02"}
+
+// compare compares two values, and reports whether they could be compared.
+func compare(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+
+	if ab, ok := a.(bool); ok {
+		a = boolInt(ab)
+	}
+	if bb, ok := b.(bool); ok {
+		b = boolInt(bb)
+	}
+
+	switch av := a.(type) {
+	case int64:
+		switch bv := b.(type) {
+		case int64:
+			return cmpInt(av, bv), true
+		case float64:
+			return cmpFloat(float64(av), bv), true
+		}
+
+	case float64:
+		switch bv := b.(type) {
+		case int64:
+			return cmpFloat(av, float64(bv)), true
+		case float64:
+			return cmpFloat(av, bv), true
+		}
+
+	case string:
+		switch bv := b.(type) {
+		case string:
+			return strings.Compare(av, bv), true
+		case time.Time:
+			c, ok := compare(b, a)
+			return -c, ok
+		}
+
+	case time.Time:
+		var bt time.Time
+		switch bv := b.(type) {
+		case time.Time:
+			bt = bv
+		case string:
+			parsed := false
+			for _, l := range timeLayouts {
+				if t, err := time.Parse(l, bv); err == nil {
+					bt, parsed = t, true
+					break
+				}
+			}
+			if !parsed {
+				return 0, false
+			}
+		default:
+			return 0, false
+		}
+		switch {
+		case av.Before(bt):
+			return -1, true
+		case av.After(bt):
+			return 1, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func cmpInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
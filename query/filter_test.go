@@ -0,0 +1,158 @@
+package query_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hojulian/microdb/query"
+)
+
+func TestFilter(t *testing.T) {
+	row := map[string]interface{}{
+		"tenant_id":  int64(42),
+		"name":       "test",
+		"score":      float32(1.5),
+		"active":     true,
+		"deleted_at": nil,
+		"created_at": time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+
+	testCases := []struct {
+		desc    string
+		expr    string
+		match   bool
+		columns []string
+		err     bool
+	}{
+		{
+			desc:    "equal",
+			expr:    "tenant_id = 42",
+			match:   true,
+			columns: []string{"tenant_id"},
+		},
+		{
+			desc:    "and, or",
+			expr:    "tenant_id = 41 OR (name = 'test' AND score > 1)",
+			match:   true,
+			columns: []string{"tenant_id", "name", "score"},
+		},
+		{
+			desc:    "in",
+			expr:    "tenant_id IN (1, 2, 3)",
+			columns: []string{"tenant_id"},
+		},
+		{
+			desc:    "not in",
+			expr:    "name NOT IN ('a', 'b')",
+			match:   true,
+			columns: []string{"name"},
+		},
+		{
+			desc:    "between",
+			expr:    "score BETWEEN 1 AND 2",
+			match:   true,
+			columns: []string{"score"},
+		},
+		{
+			desc:    "is null",
+			expr:    "deleted_at IS NULL AND active",
+			match:   true,
+			columns: []string{"deleted_at", "active"},
+		},
+		{
+			desc:    "comparison with null is unknown",
+			expr:    "NOT deleted_at = 1",
+			columns: []string{"deleted_at"},
+		},
+		{
+			desc:    "time",
+			expr:    "created_at >= '2021-01-01'",
+			match:   true,
+			columns: []string{"created_at"},
+		},
+		{
+			desc: "functions are not supported",
+			expr: "lower(name) = 'test'",
+			err:  true,
+		},
+		{
+			desc: "invalid expression",
+			expr: "tenant_id = ",
+			err:  true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			f, err := query.ParseFilter(tC.expr)
+			if tC.err {
+				assert.NotNil(t, err)
+				return
+			}
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, tC.columns, f.Columns())
+
+			match, err := f.Match(row)
+			assert.Nil(t, err)
+			assert.Equal(t, tC.match, match)
+		})
+	}
+}
+
+func TestFilterCovers(t *testing.T) {
+	f, err := query.ParseFilter("tenant_id = 42 AND (deleted_at IS NULL)")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	testCases := []struct {
+		desc   string
+		query  string
+		covers bool
+	}{
+		{
+			desc:   "same conditions",
+			query:  "SELECT * FROM users WHERE deleted_at IS NULL AND tenant_id = 42",
+			covers: true,
+		},
+		{
+			desc:   "more conditions",
+			query:  "SELECT name FROM users u WHERE (u.tenant_id = 42 AND name = 'a') AND u.deleted_at IS NULL",
+			covers: true,
+		},
+		{
+			desc:  "missing condition",
+			query: "SELECT * FROM users WHERE tenant_id = 42",
+		},
+		{
+			desc:  "or",
+			query: "SELECT * FROM users WHERE tenant_id = 42 AND deleted_at IS NULL OR name = 'a'",
+		},
+		{
+			desc:  "other value",
+			query: "SELECT * FROM users WHERE tenant_id = 43 AND deleted_at IS NULL",
+		},
+		{
+			desc:  "no where",
+			query: "SELECT * FROM users",
+		},
+		{
+			desc:  "join",
+			query: "SELECT * FROM users JOIN orders ON users.id = orders.user_id WHERE tenant_id = 42 AND deleted_at IS NULL",
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			q, err := query.Query(tC.query)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, tC.covers, f.Covers(q))
+		})
+	}
+}
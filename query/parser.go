@@ -3,12 +3,19 @@ package query //nolint // Package comment located in a different file.
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
 
 	"github.com/cube2222/octosql/parser/sqlparser"
 )
 
 // Parser provides all functionalities for parsing a SQL query.
 
+// hintPattern matches routing hint comments, e.g. "/*+ microdb:origin */".
+//nolint // Used as a constant.
+var hintPattern = regexp.MustCompile(`^/\*\+\s*microdb:(\w*)\s*\*/$`)
+
 func parseQuery(query string) (*QueryStmt, error) {
 	stmt, err := sqlparser.Parse(query)
 	if err != nil {
@@ -20,10 +27,82 @@ func parseQuery(query string) (*QueryStmt, error) {
 		return nil, fmt.Errorf("failed to parse statement: %w", err)
 	}
 	qs.originQuery = query
+	qs.stmt = stmt
+
+	if err := parseHint(query, qs); err != nil {
+		return nil, fmt.Errorf("invalid routing hint: %w", err)
+	}
 
 	return qs, nil
 }
 
+// parseHint forces a select query to the destination of its routing hint, if any. Hints are only
+// read from the comments before the first token of the query, or right after its leading SELECT
+// keyword, so that text looking like a hint elsewhere, e.g. in a string literal, is ignored.
+func parseHint(query string, qs *QueryStmt) error {
+	var m []string
+	for _, c := range leadingComments(query) {
+		if m = hintPattern.FindStringSubmatch(c); m != nil {
+			break
+		}
+	}
+	if m == nil {
+		return nil
+	}
+	if qs.queryType != QueryTypeSelect {
+		return errors.New("only select queries could be routed")
+	}
+
+	switch m[1] {
+	case "local":
+		qs.OnLocal()
+	case "origin":
+		qs.OnOrigin()
+	default:
+		return fmt.Errorf("unknown destination %q", m[1])
+	}
+
+	return nil
+}
+
+// leadingComments returns the comments of a query before its first token, and right after its
+// leading SELECT keyword.
+func leadingComments(query string) []string {
+	var (
+		cs      []string
+		keyword bool
+	)
+	for {
+		query = strings.TrimLeftFunc(query, unicode.IsSpace)
+
+		switch {
+		case strings.HasPrefix(query, "/*"):
+			end := strings.Index(query, "*/")
+			if end < 0 {
+				return cs
+			}
+			cs = append(cs, query[:end+2])
+			query = query[end+2:]
+		case strings.HasPrefix(query, "--"), strings.HasPrefix(query, "#"):
+			end := strings.IndexByte(query, '\n')
+			if end < 0 {
+				return cs
+			}
+			query = query[end+1:]
+		case !keyword && len(query) > len("SELECT") && strings.EqualFold(query[:len("SELECT")], "SELECT") &&
+			!isIdentByte(query[len("SELECT")]):
+			keyword = true
+			query = query[len("SELECT"):]
+		default:
+			return cs
+		}
+	}
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || b == '$' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
 func parseStmt(stmt sqlparser.Statement) (*QueryStmt, error) {
 	switch s := stmt.(type) {
 	case *sqlparser.Select:
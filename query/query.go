@@ -4,6 +4,9 @@ package query
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/cube2222/octosql/parser/sqlparser"
 )
 
 const (
@@ -38,6 +41,9 @@ type QueryStmt struct {
 	requiredTables   []string
 
 	query string
+	stmt  sqlparser.Statement
+	// forced is set once the destination is forced, see IsForced.
+	forced bool
 }
 
 // Query creates a new query statement.
@@ -58,15 +64,23 @@ func Query(query string) (*QueryStmt, error) {
 // This could be overrided at query-time if the query contains tables that do not exist locally.
 func (q *QueryStmt) OnLocal() *QueryStmt {
 	q.destinationType = DestinationTypeLocal
+	q.forced = true
 	return q
 }
 
 // OnOrigin forces the query to the data origin.
 func (q *QueryStmt) OnOrigin() *QueryStmt {
 	q.destinationType = DestinationTypeOrigin
+	q.forced = true
 	return q
 }
 
+// IsForced reports whether the destination of the query was forced with OnLocal, OnOrigin or a
+// routing hint, e.g. "SELECT /*+ microdb:origin */ * FROM t".
+func (q *QueryStmt) IsForced() bool {
+	return q.forced
+}
+
 // SQL converts the query into string format.
 func (q *QueryStmt) SQL() string {
 	return q.originQuery
@@ -93,3 +107,107 @@ func (q *QueryStmt) GetQueryType() QueryType {
 func (q *QueryStmt) GetRequiredTables() []string {
 	return q.requiredTables
 }
+
+// GetRequiredColumns returns the names of all the columns referred to by the query, lowercased and
+// without their table qualifiers, and "*" if it selects all columns of a table.
+func (q *QueryStmt) GetRequiredColumns() []string {
+	var cs []string
+	seen := make(map[string]bool)
+	add := func(c string) {
+		if !seen[c] {
+			seen[c] = true
+			cs = append(cs, c)
+		}
+	}
+
+	_ = sqlparser.Walk(func(n sqlparser.SQLNode) (bool, error) {
+		switch n := n.(type) {
+		case *sqlparser.ColName:
+			add(n.Name.Lowered())
+		case *sqlparser.StarExpr:
+			add("*")
+		}
+		return true, nil
+	}, q.stmt)
+
+	return cs
+}
+
+// GetTableConditions returns the conditions of the WHERE clause of a select query that only refer
+// to the columns of a table, joined with AND and without table qualifiers, e.g. "a = 1 AND b > 2".
+// Filtering the rows of the table with them before executing the query does not change its results.
+//
+// It returns an empty string if there are none, or if the table is not read once in the FROM clause
+// of the query, or if the query has outer joins. Conditions with placeholders are left out.
+func (q *QueryStmt) GetTableConditions(table string) string {
+	s, ok := q.stmt.(*sqlparser.Select)
+	if !ok || s.Where == nil {
+		return ""
+	}
+
+	var (
+		names []string
+		outer bool
+	)
+	_ = sqlparser.Walk(func(n sqlparser.SQLNode) (bool, error) {
+		switch n := n.(type) {
+		case *sqlparser.JoinTableExpr:
+			switch n.Join {
+			case sqlparser.LeftJoinStr, sqlparser.RightJoinStr,
+				sqlparser.NaturalLeftJoinStr, sqlparser.NaturalRightJoinStr:
+				outer = true
+			}
+		case *sqlparser.AliasedTableExpr:
+			if t, ok := n.Expr.(sqlparser.TableName); ok && t.Name.String() == table {
+				names = append(names, table)
+				if !n.As.IsEmpty() {
+					names[len(names)-1] = n.As.String()
+				}
+			}
+			// Tables of subqueries are not referred to by the WHERE clause.
+			return false, nil
+		}
+		return true, nil
+	}, s.From)
+	if outer || len(names) != 1 {
+		return ""
+	}
+
+	var conds []string
+	for _, c := range conjuncts(s.Where.Expr, nil) {
+		if !onlyRefersTo(c, names[0]) {
+			continue
+		}
+		if _, ok := c.(*sqlparser.OrExpr); ok {
+			c = &sqlparser.ParenExpr{Expr: c}
+		}
+		conds = append(conds, unqualified(c))
+	}
+
+	return strings.Join(conds, " AND ")
+}
+
+// onlyRefersTo reports whether an expression refers to columns qualified with a table name or
+// alias only, without placeholders or subqueries.
+func onlyRefersTo(expr sqlparser.Expr, name string) bool {
+	cols := 0
+	ok := true
+	_ = sqlparser.Walk(func(n sqlparser.SQLNode) (bool, error) {
+		switch n := n.(type) {
+		case *sqlparser.ColName:
+			cols++
+			if n.Qualifier.Name.String() != name {
+				ok = false
+			}
+		case *sqlparser.SQLVal:
+			if n.Type == sqlparser.ValArg {
+				ok = false
+			}
+		case *sqlparser.Subquery:
+			ok = false
+		}
+		return ok, nil
+	}, expr)
+
+	return ok && cols > 0
+}
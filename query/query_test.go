@@ -87,6 +87,74 @@ func TestQuery(t *testing.T) {
 			destinationType: query.DestinationTypeOrigin,
 			requiredTables:  []string{"foo", "bar"},
 		},
+		{
+			desc:            "select with origin routing hint",
+			q:               "SELECT /*+ microdb:origin */ id FROM test WHERE id = ?",
+			queryType:       query.QueryTypeSelect,
+			destinationType: query.DestinationTypeOrigin,
+			requiredTables:  []string{"test"},
+		},
+		{
+			desc:            "select with local routing hint",
+			q:               "/*+ microdb:local */ SELECT id FROM test",
+			queryType:       query.QueryTypeSelect,
+			destinationType: query.DestinationTypeLocal,
+			requiredTables:  []string{"test"},
+		},
+		{
+			desc:            "select with routing hint after comment",
+			q:               "-- find test\nselect /* columns */ /*+ microdb:origin */ id FROM test",
+			queryType:       query.QueryTypeSelect,
+			destinationType: query.DestinationTypeOrigin,
+			requiredTables:  []string{"test"},
+		},
+		{
+			desc:            "select with routing hint in string literal",
+			q:               "SELECT id FROM test WHERE name = '/*+ microdb:origin */'",
+			queryType:       query.QueryTypeSelect,
+			destinationType: query.DestinationTypeLocal,
+			requiredTables:  []string{"test"},
+		},
+		{
+			desc:            "select with routing hint in first column",
+			q:               "SELECT '/*+ microdb:origin */' FROM test",
+			queryType:       query.QueryTypeSelect,
+			destinationType: query.DestinationTypeLocal,
+			requiredTables:  []string{"test"},
+		},
+		{
+			desc:            "select with routing hint after columns",
+			q:               "SELECT id /*+ microdb:origin */ FROM test",
+			queryType:       query.QueryTypeSelect,
+			destinationType: query.DestinationTypeLocal,
+			requiredTables:  []string{"test"},
+		},
+		{
+			desc:             "insert with routing hint in string literal",
+			q:                "INSERT INTO test (id, name) VALUES (1, '/*+ microdb:local */')",
+			queryType:        query.QueryTypeInsert,
+			destinationType:  query.DestinationTypeOrigin,
+			destinationTable: "test",
+			requiredTables:   []string{"test"},
+		},
+		{
+			desc: "insert with routing hint",
+			q:    "/*+ microdb:local */ INSERT INTO test (id) VALUES (1)",
+			err: fmt.Errorf(
+				"failed to parse query: %w",
+				fmt.Errorf(
+					"invalid routing hint: %w",
+					errors.New("only select queries could be routed"))),
+		},
+		{
+			desc: "unknown routing hint",
+			q:    "SELECT /*+ microdb:elsewhere */ id FROM test",
+			err: fmt.Errorf(
+				"failed to parse query: %w",
+				fmt.Errorf(
+					"invalid routing hint: %w",
+					errors.New(`unknown destination "elsewhere"`))),
+		},
 		{
 			desc: "unsupported operation: delete",
 			q:    "DELETE FROM a1, a2 USING t1 AS a1 INNER JOIN t2 AS a2 WHERE a1.id=a2.id",
@@ -114,3 +182,90 @@ func TestQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryRequiredColumns(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		q       string
+		columns []string
+	}{
+		{
+			desc:    "select columns",
+			q:       "SELECT a.age, a.name FROM anacondas a WHERE a.Age > ? ORDER BY id",
+			columns: []string{"age", "name", "id"},
+		},
+		{
+			desc:    "select all",
+			q:       "SELECT * FROM anacondas WHERE name = 'a'",
+			columns: []string{"*", "name"},
+		},
+		{
+			desc:    "join",
+			q:       "SELECT p.name, c.* FROM people p LEFT JOIN cities c ON p.city = c.name",
+			columns: []string{"name", "*", "city"},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			q, err := query.Query(tC.q)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, tC.columns, q.GetRequiredColumns())
+		})
+	}
+}
+
+func TestQueryTableConditions(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		q     string
+		table string
+		conds string
+	}{
+		{
+			desc:  "join",
+			q:     "SELECT u.name, o.total FROM users u JOIN orders o ON u.id = o.user_id WHERE u.Tenant_ID = 42 AND o.total > 10 AND u.id = o.id",
+			table: "users",
+			conds: "tenant_id = 42",
+		},
+		{
+			desc:  "table name as qualifier",
+			q:     "SELECT * FROM users JOIN orders ON users.id = orders.user_id WHERE (orders.total > 10 OR orders.total < 0) AND users.name = 'a'",
+			table: "orders",
+			conds: "(total > 10 or total < 0)",
+		},
+		{
+			desc:  "placeholders are left out",
+			q:     "SELECT * FROM users u JOIN orders o ON u.id = o.user_id WHERE u.tenant_id = ? AND u.deleted = 0",
+			table: "users",
+			conds: "deleted = 0",
+		},
+		{
+			desc:  "unqualified columns are left out",
+			q:     "SELECT * FROM users u JOIN orders o ON u.id = o.user_id WHERE tenant_id = 42",
+			table: "users",
+		},
+		{
+			desc:  "outer join",
+			q:     "SELECT * FROM users u LEFT JOIN orders o ON u.id = o.user_id WHERE o.total > 10",
+			table: "orders",
+		},
+		{
+			desc:  "self join",
+			q:     "SELECT * FROM users a JOIN users b ON a.id = b.manager_id WHERE a.tenant_id = 42",
+			table: "users",
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			q, err := query.Query(tC.q)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, tC.conds, q.GetTableConditions(tC.table))
+		})
+	}
+}